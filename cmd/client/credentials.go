@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/credentials"
+)
+
+// runCredentials handles the "credentials" subcommand
+func runCredentials(args []string) int {
+	if len(args) < 1 || args[0] != "set" {
+		fmt.Println("Usage: credentials set -u <user> [-backend auto|secret-service|file] [-file path]")
+		fmt.Println("The password is read from stdin.")
+		return 1
+	}
+
+	fs := flag.NewFlagSet("credentials set", flag.ExitOnError)
+	user := fs.String("u", "", "Login User (Phone Number or Other)")
+	fs.StringVar(user, "user", "", "Login User (Phone Number or Other)")
+	backend := fs.String("backend", credentials.BackendAuto, "Credential store: auto, secret-service or file")
+	file := fs.String("file", "", "Encrypted credentials file (default "+credentials.DefaultFilePath()+")")
+	fs.Parse(args[1:])
+
+	if *user == "" {
+		fmt.Println("Error: user is required")
+		fs.Usage()
+		return 1
+	}
+
+	store, err := credentials.Open(*backend, *file)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	restore := noEcho(os.Stdin)
+	password, err := readPasswordLine(os.Stdin)
+	restore()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	if err := store.Set(*user, password); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Stored password for %s in %s\n", *user, store.Name())
+	return 0
+}
+
+// noEcho turns off terminal echo while a password is typed on f, it returns
+// a func restoring it. Nothing changes when f is not a terminal.
+func noEcho(f *os.File) func() {
+	fi, err := f.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return func() {}
+	}
+	if stty(f, "-echo") != nil {
+		return func() {}
+	}
+	return func() {
+		stty(f, "echo")
+		// The newline typed after the password was not echoed
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// stty runs stty on the terminal f
+func stty(f *os.File, args ...string) error {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	return cmd.Run()
+}
+
+// readPasswordLine reads the first line of r as a password. It reads byte by
+// byte so nothing after the line is consumed, stdin may still carry an SMS code.
+func readPasswordLine(r io.Reader) (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	password := strings.TrimRight(string(line), "\r")
+	if password == "" {
+		return "", errors.New("empty password")
+	}
+	return password, nil
+}
+
+// readPasswordFile reads a password from the first line of a file
+func readPasswordFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	password, err := readPasswordLine(f)
+	if err != nil {
+		return "", fmt.Errorf("password file %s: %w", path, err)
+	}
+	return password, nil
+}
+
+// lookupPassword returns the stored password of user, or "" when none is stored
+func lookupPassword(backend, file, user string) (string, error) {
+	store, err := credentials.Open(backend, file)
+	if err != nil {
+		return "", err
+	}
+	password, err := store.Get(user)
+	if errors.Is(err, credentials.ErrNotFound) {
+		return "", nil
+	}
+	return password, err
+}
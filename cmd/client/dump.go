@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/dump"
+)
+
+// runDump handles the "dump" subcommand
+func runDump(args []string) int {
+	if len(args) < 2 || args[0] != "inspect" {
+		fmt.Println("Usage: dump inspect <algo_dump_*.bin>...")
+		return 1
+	}
+
+	status := 0
+	for i, path := range args[1:] {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("File: %s\n", path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			status = 1
+			continue
+		}
+		if err := dump.Inspect(os.Stdout, data); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			status = 1
+		}
+	}
+	return status
+}
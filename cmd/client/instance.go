@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/backoff"
+	"github.com/Rsplwe/ESurfingDialer/internal/client"
+	"github.com/Rsplwe/ESurfingDialer/internal/config"
+	"github.com/Rsplwe/ESurfingDialer/internal/models"
+	"github.com/Rsplwe/ESurfingDialer/internal/network"
+	"github.com/Rsplwe/ESurfingDialer/internal/smscode"
+	"github.com/Rsplwe/ESurfingDialer/internal/states"
+)
+
+// instance is one account dialing with its own state, session and interface
+type instance struct {
+	name    string
+	profile config.Profile
+	state   *states.State
+	client  *client.Client
+	logger  *slog.Logger
+}
+
+// newInstance creates an instance, a non-empty name is added to its log records
+func newInstance(name string, profile config.Profile, smsCode string, logger *slog.Logger) (*instance, error) {
+	provider, err := smscode.Parse(profile.SMS.Provider)
+	if err != nil {
+		return nil, err
+	}
+	probes, err := network.ParseProbes(profile.Probe.Targets)
+	if err != nil {
+		return nil, err
+	}
+	liveness, err := network.ParseLiveness(profile.Probe.Liveness, profile.Probe.LivenessTarget)
+	if err != nil {
+		return nil, err
+	}
+	liveness.Interval = profile.Probe.LivenessInterval.Value()
+
+	options := &models.Options{
+		LoginUser:     profile.User,
+		LoginPassword: profile.Password,
+		SmsCode:       smsCode,
+		CodeCooldown:  time.Duration(profile.SMS.Cooldown),
+		ProbeInterval: time.Duration(profile.Timing.ProbeInterval),
+
+		HeartbeatFailures: profile.Timing.HeartbeatFailures,
+		Backoff: backoff.Policy{
+			Initial:    time.Duration(profile.Timing.InitialDelay()),
+			Max:        time.Duration(profile.Timing.Backoff.Max),
+			Multiplier: profile.Timing.Backoff.Multiplier,
+			Jitter:     profile.Timing.Backoff.Jitter,
+		},
+	}
+
+	st := states.New()
+	c := client.New(options, st)
+
+	if name != "" {
+		logger = logger.With("instance", name)
+	}
+	c.SetLogger(logger)
+	c.SetProbes(network.ProbeConfig{Probes: probes, Parallel: profile.Probe.IsParallel()})
+	c.SetLiveness(liveness)
+	c.SetCodeProvider(&smscode.Retry{
+		Provider: provider,
+		Timeout:  profile.SMS.Timeout.Value(),
+		Attempts: profile.SMS.Attempts(),
+		Before: func(ctx context.Context) error {
+			_, err := c.ResendCode(ctx, true)
+			return err
+		},
+	})
+
+	if profile.MAC != "" {
+		st.SetMacAddress(profile.MAC)
+	}
+	st.Refresh()
+	if profile.Interface != "" {
+		st.SetInterface(profile.Interface)
+		logger.Info("Binding to interface", "interface", profile.Interface)
+	}
+
+	return &instance{name: name, profile: profile, state: st, client: c, logger: logger}, nil
+}
+
+// run dials until ctx is cancelled, then logs out
+func (in *instance) run(ctx context.Context) {
+	in.client.Run(ctx)
+	if ctx.Err() != nil {
+		in.logger.Info("Shutting down")
+	}
+
+	if in.state.IsLogged() {
+		termCtx, cancel := context.WithTimeout(context.Background(), termTimeout)
+		if err := in.client.Term(termCtx); err != nil {
+			in.logger.Error("Logout failed", "error", err)
+		}
+		cancel()
+	}
+	in.client.Close()
+	in.logger.Info("Stopped", in.status()...)
+}
+
+// label names the instance in the control API and metrics
+func (in *instance) label() string {
+	if in.name == "" {
+		return "default"
+	}
+	return in.name
+}
+
+// status describes the instance as log attributes
+func (in *instance) status() []any {
+	return []any{
+		"user", in.profile.User,
+		"interface", in.profile.Interface,
+		"logged_in", in.state.IsLogged(),
+		"user_ip", in.state.UserIP(),
+		"ac_ip", in.state.ACIP(),
+	}
+}
+
+// runInstances runs every instance concurrently and waits for all of them
+func runInstances(ctx context.Context, instances []*instance) {
+	var wg sync.WaitGroup
+	for _, in := range instances {
+		wg.Add(1)
+		go func(in *instance) {
+			defer wg.Done()
+			in.run(ctx)
+		}(in)
+	}
+	wg.Wait()
+}
@@ -1,28 +1,66 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
+	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
-	"github.com/Rsplwe/ESurfingDialer/internal/client"
-	"github.com/Rsplwe/ESurfingDialer/internal/models"
-	"github.com/Rsplwe/ESurfingDialer/internal/session"
-	"github.com/Rsplwe/ESurfingDialer/internal/states"
+	"github.com/Rsplwe/ESurfingDialer/internal/cipher"
+	"github.com/Rsplwe/ESurfingDialer/internal/config"
+	"github.com/Rsplwe/ESurfingDialer/internal/control"
+	"github.com/Rsplwe/ESurfingDialer/internal/credentials"
+	"github.com/Rsplwe/ESurfingDialer/internal/logging"
+	"github.com/Rsplwe/ESurfingDialer/internal/metrics"
+	"github.com/Rsplwe/ESurfingDialer/internal/network"
 )
 
+// termTimeout bounds the logout request sent on shutdown
+const termTimeout = 5 * time.Second
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "dump":
+			os.Exit(runDump(os.Args[2:]))
+		case "credentials":
+			os.Exit(runCredentials(os.Args[2:]))
+		}
+	}
+	if err := run(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// run runs the dialer until it is stopped. Its errors are logged already,
+// main only exits with a failure status once the deferred cleanup has run.
+func run() error {
 	// Parse command line arguments
 	user := flag.String("u", "", "Login User (Phone Number or Other)")
 	flag.StringVar(user, "user", "", "Login User (Phone Number or Other)")
 	
-	password := flag.String("p", "", "Login User Password")
-	flag.StringVar(password, "password", "", "Login User Password")
+	password := flag.String("p", "", "Login User Password (visible in ps, prefer $"+config.EnvPassword+" or -c)")
+	flag.StringVar(password, "password", "", "Login User Password (visible in ps, prefer $"+config.EnvPassword+" or -c)")
+	
+	passwordFile := flag.String("password-file", "", "Read the password from the first line of a file")
+	passwordStdin := flag.Bool("password-stdin", false, "Read the password from stdin")
+	
+	credBackend := flag.String("credentials-backend", credentials.BackendAuto, "Credential store used when no password is given: auto, secret-service or file")
+	credFile := flag.String("credentials-file", "", "Encrypted credentials file for the file backend")
 	
 	smsCode := flag.String("s", "", "Pre-enter verification code")
 	flag.StringVar(smsCode, "sms", "", "Pre-enter verification code")
+	smsProvider := flag.String("sms-provider", "", "Verification code source: stdin, control, file:PATH, http://URL or command:CMDLINE, or $"+config.EnvSMS)
+	smsTimeout := flag.Duration("sms-timeout", 0, "Give up waiting for a verification code after this long, 0 waits forever")
+	smsRetries := flag.Int("sms-retries", 0, "Attempts to get a verification code after the first one times out or fails, each requests a new code")
+	smsCooldown := flag.Duration("sms-cooldown", 0, "Minimum time between verification code requests (default 1m0s)")
 	
 	macAddr := flag.String("m", "", "MAC address (e.g., aa:bb:cc:dd:ee:ff)")
 	flag.StringVar(macAddr, "mac", "", "MAC address (e.g., aa:bb:cc:dd:ee:ff)")
@@ -30,47 +68,277 @@ func main() {
 	iface := flag.String("i", "", "Network interface to bind (e.g., eth0, wan)")
 	flag.StringVar(iface, "interface", "", "Network interface to bind (e.g., eth0, wan)")
 	
+	keyring := flag.String("k", "", "Keyring file with extra algorithm keys (JSON)")
+	flag.StringVar(keyring, "keyring", "", "Keyring file with extra algorithm keys (JSON)")
+	
+	configPath := flag.String("c", "", "Config file with profiles (JSON), or $"+config.EnvConfig)
+	flag.StringVar(configPath, "config", "", "Config file with profiles (JSON), or $"+config.EnvConfig)
+	
+	profileName := flag.String("profile", "", "Profile to use from the config file, or $"+config.EnvProfile+"; a comma separated list runs several")
+	allProfiles := flag.Bool("all", false, "Run every profile in the config file concurrently")
+	
+	heartbeatFailures := flag.Int("heartbeat-failures", 0, "Consecutive failed heartbeats before logging in again (default 3)")
+	backoffInitial := flag.Duration("backoff-initial", 0, "First delay after a failed probe or login (default 5s)")
+	backoffMax := flag.Duration("backoff-max", 0, "Longest delay between retries of failed probes and logins (default 5m0s)")
+	backoffMultiplier := flag.Float64("backoff-multiplier", 0, "Growth of the retry delay per consecutive failure (default 2)")
+	backoffJitter := flag.Float64("backoff-jitter", 0, "Random spread of the retry delay as a fraction of it, negative disables it (default 0.2)")
+
+	probes := flag.String("probes", "", "Comma separated connectivity check targets: presets ("+strings.Join(network.ProbePresets(), ", ")+"), URL or URL|STATUS|BODY, or $"+config.EnvProbes+" (default xiaomi)")
+	probeParallel := flag.Bool("probe-parallel", false, "Race the connectivity check targets instead of trying them in order")
+	liveness := flag.String("liveness", "", "Cheap check while logged in, probing the targets only when it fails: http, tcp or dns (default off)")
+	livenessTarget := flag.String("liveness-target", "", "Liveness target: a probe for http (default the first -probes), host:port for tcp (default "+network.DefaultLivenessTCP+"), a host name for dns (default "+network.DefaultLivenessDNS+")")
+	livenessInterval := flag.Duration("liveness-interval", 0, "Delay between liveness checks, 0 uses the probe interval")
+
+	controlAddr := flag.String("control", "", "Serve the local control API on a loopback address (e.g., 127.0.0.1:8321) or unix:PATH")
+	controlToken := flag.String("control-token", "", "Token the control API requires, allows non-loopback addresses (visible in ps, prefer $"+config.EnvControl+")")
+	metricsAddr := flag.String("metrics", "", "Serve Prometheus metrics on an address (e.g., 127.0.0.1:9321)")
+	listAlgorithms := flag.Bool("list-algorithms", false, "List supported algorithms and exit")
+
+	logLevel := flag.String("log-level", "info", "Log level: debug, info, warn or error")
+	logFormat := flag.String("log-format", logging.FormatText, "Log format: text or json")
+	logFile := flag.String("log-file", "", "Write logs to a file instead of stdout")
+	logMaxSize := flag.Int64("log-max-size", 10, "Rotate the log file after this many megabytes, 0 never rotates")
+	logMaxBackups := flag.Int("log-max-backups", 3, "Rotated log files to keep")
+	
 	flag.Parse()
 
-	if *user == "" || *password == "" {
-		fmt.Println("Error: user and password are required")
+	logger, logCloser, err := logging.New(logging.Options{
+		Level:      *logLevel,
+		Format:     *logFormat,
+		File:       *logFile,
+		MaxSize:    *logMaxSize << 20,
+		MaxBackups: *logMaxBackups,
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return err
+	}
+	defer logCloser.Close()
+	slog.SetDefault(logger)
+
+	if *keyring != "" {
+		n, err := cipher.LoadKeyring(*keyring)
+		if err != nil {
+			return fail(logger, "Startup failed", err)
+		}
+		logger.Info("Loaded keyring", "algorithms", n, "file", *keyring)
+	}
+
+	if *listAlgorithms {
+		for _, algo := range cipher.ListAlgorithms() {
+			fmt.Printf("%s  %-12s %s\n", algo.ID, algo.Type, algo.Name)
+		}
+		return nil
+	}
+
+	// Password flags, the first one given wins
+	if *password == "" && *passwordFile != "" {
+		p, err := readPasswordFile(*passwordFile)
+		if err != nil {
+			return fail(logger, "Startup failed", err)
+		}
+		*password = p
+	}
+	if *password == "" && *passwordStdin {
+		p, err := readPasswordLine(os.Stdin)
+		if err != nil {
+			return fail(logger, "Reading password from stdin failed", err)
+		}
+		*password = p
+	}
+
+	// Cancel the clients on SIGINT/SIGTERM, they log out once Run has returned
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	flags := config.Profile{
+		User:      *user,
+		Password:  *password,
+		MAC:       *macAddr,
+		Interface: *iface,
+		Timing: config.Timing{
+			HeartbeatFailures: *heartbeatFailures,
+			Backoff: config.Backoff{
+				Initial:    config.Duration(*backoffInitial),
+				Max:        config.Duration(*backoffMax),
+				Multiplier: *backoffMultiplier,
+				Jitter:     *backoffJitter,
+			},
+		},
+		Probe: config.Probe{
+			Targets:  config.SplitList(*probes),
+			Parallel: setFlag("probe-parallel", probeParallel),
+
+			Liveness:         *liveness,
+			LivenessTarget:   *livenessTarget,
+			LivenessInterval: (*config.Duration)(setFlag("liveness-interval", livenessInterval)),
+		},
+		SMS: config.SMS{
+			Provider: *smsProvider,
+			Timeout:  (*config.Duration)(setFlag("sms-timeout", smsTimeout)),
+			Retries:  setFlag("sms-retries", smsRetries),
+			Cooldown: config.Duration(*smsCooldown),
+		},
+	}
+
+	if *allProfiles || strings.Contains(*profileName, ",") {
+		if *smsCode != "" {
+			return fail(logger, "Startup failed", errors.New("-sms cannot be used when running several profiles"))
+		}
+		instances, err := loadInstances(*configPath, *profileName, *allProfiles, config.FromEnv().Merge(flags), *credBackend, *credFile, logger)
+		if err != nil {
+			return fail(logger, "Startup failed", err)
+		}
+		return serve(ctx, logger, *controlAddr, *controlToken, *metricsAddr, instances)
+	}
+
+	// Precedence is flags > environment > config file > credential store
+	profile, err := config.Resolve(*configPath, *profileName, flags)
+	if err != nil {
+		return fail(logger, "Startup failed", err)
+	}
+
+	if profile.User != "" && profile.Password == "" {
+		p, err := lookupPassword(*credBackend, *credFile, profile.User)
+		if err != nil {
+			logger.Warn("Credential store lookup failed", "error", err)
+		}
+		profile.Password = p
+	}
+
+	if profile.User == "" || profile.Password == "" {
 		flag.Usage()
-		os.Exit(1)
+		return fail(logger, "Startup failed", errors.New("user and password are required"))
+	}
+
+	in, err := newInstance("", profile, *smsCode, logger)
+	if err != nil {
+		return fail(logger, "Startup failed", err)
 	}
+	return serve(ctx, logger, *controlAddr, *controlToken, *metricsAddr, []*instance{in})
+}
+
+// setFlag returns v when the flag name was given, nil otherwise, so an
+// explicit false or zero overrides the config file
+func setFlag[T any](name string, v *T) *T {
+	var set bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	if !set {
+		return nil
+	}
+	return v
+}
 
-	options := &models.Options{
-		LoginUser:     *user,
-		LoginPassword: *password,
-		SmsCode:       *smsCode,
+// fail logs err and returns it, for startup errors once the logger exists
+func fail(logger *slog.Logger, msg string, err error) error {
+	logger.Error(msg, "error", err)
+	return err
+}
+
+// serve runs the instances, with the control API and metrics when their addresses are set
+func serve(ctx context.Context, logger *slog.Logger, controlAddr, controlToken, metricsAddr string, instances []*instance) error {
+	if controlToken == "" {
+		controlToken = os.Getenv(config.EnvControl)
+	}
+	for _, in := range instances {
+		if in.profile.SMS.Provider == "control" && controlAddr == "" {
+			in.logger.Warn("SMS provider control needs -control to receive codes")
+		}
 	}
 
-	c := client.New(options)
+	if metricsAddr != "" {
+		l, err := net.Listen("tcp", metricsAddr)
+		if err != nil {
+			return fail(logger, "Metrics listener failed", err)
+		}
+		logger.Info("Metrics listening", "addr", metricsAddr)
 
-	// Setup signal handler for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+		for _, in := range instances {
+			in.client.SetMetrics(metrics.NewDialer(in.label()))
+		}
+		go func() {
+			if err := metrics.Serve(ctx, l); err != nil {
+				logger.Error("Metrics server failed", "error", err)
+			}
+		}()
+	}
 
-	go func() {
-		<-sigChan
-		fmt.Println("\nShutting down...")
-		states.IsRunning = false
-		if states.IsLogged {
-			c.Term()
+	if controlAddr != "" {
+		l, err := control.Listen(controlAddr, controlToken != "")
+		if err != nil {
+			return fail(logger, "Control API listener failed", err)
 		}
-		if session.IsInitialized() {
-			session.Free()
+		logger.Info("Control API listening", "addr", controlAddr)
+
+		targets := make([]control.Instance, len(instances))
+		for i, in := range instances {
+			in.client.SetRemoteCode(true)
+			targets[i] = control.Instance{Name: in.label(), Client: in.client}
 		}
-		os.Exit(0)
-	}()
+		go func() {
+			if err := control.New(controlToken, targets...).Serve(ctx, l); err != nil {
+				logger.Error("Control API server failed", "error", err)
+			}
+		}()
+	}
+
+	runInstances(ctx, instances)
+	return nil
+}
+
+// loadInstances creates an instance for each selected profile of the config file
+// with over, the environment and flag settings, applied on top of each
+func loadInstances(path, names string, all bool, over config.Profile, credBackend, credFile string, logger *slog.Logger) ([]*instance, error) {
+	if set := over.AccountSettings(); len(set) > 0 {
+		return nil, fmt.Errorf("%s cannot be given by flags or the environment when running several profiles, set them in each profile", strings.Join(set, ", "))
+	}
+	if path == "" {
+		path = os.Getenv(config.EnvConfig)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("running several profiles requires a config file")
+	}
+	f, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
 
-	// Refresh states and run client
-	states.RefreshStates()
-	if *macAddr != "" {
-		states.MacAddress = *macAddr
+	selected := f.Names()
+	if !all {
+		selected = strings.Split(names, ",")
 	}
-	if *iface != "" {
-		states.Interface = *iface
-		fmt.Printf("Binding to interface: %s\n", *iface)
+
+	instances := make([]*instance, 0, len(selected))
+	ifaces := make(map[string]string)
+	for _, name := range selected {
+		name = strings.TrimSpace(name)
+		profile, err := f.Profile(name)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %w", path, err)
+		}
+		profile = profile.Merge(over)
+		if profile.User != "" && profile.Password == "" {
+			if profile.Password, err = lookupPassword(credBackend, credFile, profile.User); err != nil {
+				return nil, fmt.Errorf("profile %s: credential store: %w", name, err)
+			}
+		}
+		if profile.User == "" || profile.Password == "" {
+			return nil, fmt.Errorf("profile %s: user and password are required", name)
+		}
+		// Two accounts cannot share a route, so each needs its own interface
+		if other, ok := ifaces[profile.Interface]; ok {
+			return nil, fmt.Errorf("profiles %s and %s use the same interface %q", other, name, profile.Interface)
+		}
+		ifaces[profile.Interface] = name
+		in, err := newInstance(name, profile, "", logger)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		instances = append(instances, in)
 	}
-	c.Run()
+	return instances, nil
 }
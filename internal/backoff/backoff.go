@@ -0,0 +1,94 @@
+// Package backoff computes exponentially growing retry delays with jitter.
+package backoff
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Defaults for zero Policy fields
+const (
+	DefaultInitial    = 5 * time.Second
+	DefaultMax        = 5 * time.Minute
+	DefaultMultiplier = 2.0
+	DefaultJitter     = 0.2
+)
+
+// Policy describes how delays grow between consecutive failures
+type Policy struct {
+	Initial    time.Duration // First delay
+	Max        time.Duration // Upper bound of the delay before jitter
+	Multiplier float64       // Growth factor per failure, at least 1
+	Jitter     float64       // Random spread as a fraction of the delay up to 1, negative disables it
+}
+
+// withDefaults fills zero fields and clamps invalid ones
+func (p Policy) withDefaults() Policy {
+	if p.Initial <= 0 {
+		p.Initial = DefaultInitial
+	}
+	if p.Max <= 0 {
+		p.Max = DefaultMax
+	}
+	if p.Max < p.Initial {
+		p.Max = p.Initial
+	}
+	if p.Multiplier == 0 {
+		p.Multiplier = DefaultMultiplier
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 1
+	}
+	if p.Jitter == 0 {
+		p.Jitter = DefaultJitter
+	}
+	p.Jitter = math.Min(math.Max(p.Jitter, 0), 1)
+	return p
+}
+
+// Backoff hands out the delays of a Policy, it is safe for concurrent use
+type Backoff struct {
+	mu       sync.Mutex
+	policy   Policy
+	failures int
+	rand     *rand.Rand
+}
+
+// New creates a Backoff, zero Policy fields use the defaults
+func New(p Policy) *Backoff {
+	return &Backoff{
+		policy: p.withDefaults(),
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Next records a failure and returns how long to wait before retrying
+func (b *Backoff) Next() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := b.policy
+	d := float64(p.Initial) * math.Pow(p.Multiplier, float64(b.failures))
+	d = math.Min(d, float64(p.Max))
+	b.failures++
+
+	// Spread the delay evenly over d ± Jitter*d
+	d += d * p.Jitter * (2*b.rand.Float64() - 1)
+	return time.Duration(d)
+}
+
+// Reset starts again from the initial delay after a success
+func (b *Backoff) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+}
+
+// Failures returns the number of failures since the last Reset
+func (b *Backoff) Failures() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failures
+}
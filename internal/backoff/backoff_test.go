@@ -0,0 +1,90 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextGrowth(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		want   []time.Duration
+	}{
+		{
+			"doubles up to max",
+			Policy{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2, Jitter: -1},
+			[]time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			"triples",
+			Policy{Initial: 100 * time.Millisecond, Max: time.Minute, Multiplier: 3, Jitter: -1},
+			[]time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, 2700 * time.Millisecond},
+		},
+		{
+			"multiplier below one stays constant",
+			Policy{Initial: time.Second, Max: time.Minute, Multiplier: 0.5, Jitter: -1},
+			[]time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			"max below initial is raised to it",
+			Policy{Initial: 2 * time.Second, Max: time.Second, Jitter: -1},
+			[]time.Duration{2 * time.Second, 2 * time.Second},
+		},
+		{
+			"defaults",
+			Policy{Jitter: -1},
+			[]time.Duration{DefaultInitial, 2 * DefaultInitial, 4 * DefaultInitial},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New(tt.policy)
+			for i, want := range tt.want {
+				if got := b.Next(); got != want {
+					t.Errorf("delay %d = %s, want %s", i, got, want)
+				}
+			}
+			if got := b.Failures(); got != len(tt.want) {
+				t.Errorf("Failures() = %d, want %d", got, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestNextJitter(t *testing.T) {
+	tests := []struct {
+		jitter   float64
+		min, max time.Duration
+	}{
+		{0.2, 800 * time.Millisecond, 1200 * time.Millisecond},
+		{0, 800 * time.Millisecond, 1200 * time.Millisecond}, // Zero uses DefaultJitter
+		{0.5, 500 * time.Millisecond, 1500 * time.Millisecond},
+		{3, 0, 2 * time.Second}, // Clamped to 1
+		{-1, time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		b := New(Policy{Initial: time.Second, Max: time.Second, Jitter: tt.jitter})
+		for i := 0; i < 200; i++ {
+			if d := b.Next(); d < tt.min || d > tt.max {
+				t.Fatalf("jitter %v: delay %s outside [%s, %s]", tt.jitter, d, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	b := New(Policy{Initial: time.Second, Max: time.Minute, Jitter: -1})
+	b.Next()
+	b.Next()
+	if got := b.Failures(); got != 2 {
+		t.Fatalf("Failures() = %d, want 2", got)
+	}
+	b.Reset()
+	if got := b.Failures(); got != 0 {
+		t.Errorf("Failures() after Reset = %d, want 0", got)
+	}
+	if got := b.Next(); got != time.Second {
+		t.Errorf("delay after Reset = %s, want the initial delay", got)
+	}
+}
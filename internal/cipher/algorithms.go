@@ -0,0 +1,59 @@
+package cipher
+
+// Built-in algorithms, new Algo-IDs seen in the field are added here
+func init() {
+	MustRegister(Algorithm{
+		ID:   "CAFBCBAD-B6E7-4CAB-8A67-14D39F00CE1E",
+		Name: "AES/CBC/NoPadding",
+		Type: "aes-cbc",
+		Keys: StaticKeys(IV_CAFBCBAD, Key1_CAFBCBAD, Key2_CAFBCBAD),
+	})
+	MustRegister(Algorithm{
+		ID:   "A474B1C2-3DE0-4EA2-8C5F-7093409CE6C4",
+		Name: "AES/ECB/NoPadding",
+		Type: "aes-ecb",
+		Keys: StaticKeys(nil, Key1_A474B1C2, Key2_A474B1C2),
+	})
+	MustRegister(Algorithm{
+		ID:   "5BFBA864-BBA9-42DB-8EAD-49B5F412BD81",
+		Name: "DESede/CBC/NoPadding",
+		Type: "desede-cbc",
+		Keys: StaticKeys(IV_5BFBA864, Key1_5BFBA864, Key2_5BFBA864),
+	})
+	MustRegister(Algorithm{
+		ID:   "6E0B65FF-0B5B-459C-8FCE-EC7F2BEA9FF5",
+		Name: "DESede/ECB/NoPadding",
+		Type: "desede-ecb",
+		Keys: StaticKeys(nil, Key1_6E0B65FF, Key2_6E0B65FF),
+	})
+	MustRegister(Algorithm{
+		ID:   "B809531F-0007-4B5B-923B-4BD560398113",
+		Name: "ZUC-128",
+		Type: "zuc",
+		Keys: StaticKeys(IV_B809531F, Key_B809531F),
+	})
+	MustRegister(Algorithm{
+		ID:   "F3974434-C0DD-4C20-9E87-DDB6814A1C48",
+		Name: "SM4/CBC/PKCS5Padding",
+		Type: "sm4-cbc",
+		Keys: StaticKeys(IV_F3974434, Key_F3974434),
+	})
+	MustRegister(Algorithm{
+		ID:   "ED382482-F72C-4C41-A76D-28EEA0F1F2AF",
+		Name: "SM4/ECB/NoPadding",
+		Type: "sm4-ecb",
+		Keys: StaticKeys(nil, Key_ED382482),
+	})
+	MustRegister(Algorithm{
+		ID:   "B3047D4E-67DF-4864-A6A5-DF9B9E525C79",
+		Name: "XTEA Non-standard",
+		Type: "modxtea",
+		Keys: StaticKeys(nil, wordsToBytes(Key1_B3047D4E), wordsToBytes(Key2_B3047D4E), wordsToBytes(Key3_B3047D4E)),
+	})
+	MustRegister(Algorithm{
+		ID:   "C32C68F9-CA81-4260-A329-BBAFD1A9CCD1",
+		Name: "XTEA-IV Non-standard",
+		Type: "modxtea-iv",
+		Keys: StaticKeys(wordsToBytes(IV_C32C68F9), wordsToBytes(Key1_C32C68F9), wordsToBytes(Key2_C32C68F9), wordsToBytes(Key3_C32C68F9)),
+	})
+}
@@ -1,11 +1,8 @@
 package cipher
 
 import (
-	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
-	"encoding/hex"
-	"strings"
 )
 
 // AESCBC implements AES CBC mode encryption/decryption
@@ -24,15 +21,14 @@ func NewAESCBC(key1, key2, iv []byte) *AESCBC {
 	}
 }
 
-func (a *AESCBC) aesEncrypt(data, key []byte) []byte {
+func (a *AESCBC) aesEncrypt(data, key []byte) ([]byte, error) {
 	// Pad data to block size
-	paddedData := data
-	if len(data)%aes.BlockSize != 0 {
-		paddedData = make([]byte, (len(data)/aes.BlockSize+1)*aes.BlockSize)
-		copy(paddedData, data)
-	}
+	paddedData := zeroPad(data, aes.BlockSize)
 
-	block, _ := aes.NewCipher(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
 	ciphertext := make([]byte, len(paddedData))
 	mode := cipher.NewCBCEncrypter(block, a.iv)
 	mode.CryptBlocks(ciphertext, paddedData)
@@ -41,28 +37,57 @@ func (a *AESCBC) aesEncrypt(data, key []byte) []byte {
 	result := make([]byte, len(a.iv)+len(ciphertext))
 	copy(result, a.iv)
 	copy(result[len(a.iv):], ciphertext)
-	return result
+	return result, nil
 }
 
-func (a *AESCBC) aesDecrypt(data, key []byte) []byte {
-	block, _ := aes.NewCipher(key)
+func (a *AESCBC) aesDecrypt(data, key []byte) ([]byte, error) {
+	// Each layer carries its IV in front of the ciphertext
+	if err := checkBlocks(data, aes.BlockSize, aes.BlockSize); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	data = data[aes.BlockSize:]
 	plaintext := make([]byte, len(data))
 	mode := cipher.NewCBCDecrypter(block, a.iv)
 	mode.CryptBlocks(plaintext, data)
-	return plaintext
+	return plaintext, nil
 }
 
-func (a *AESCBC) Encrypt(text string) string {
-	r1 := a.aesEncrypt([]byte(text), a.key1)
-	r2 := a.aesEncrypt(r1, a.key2)
-	return strings.ToUpper(hex.EncodeToString(r2))
+func (a *AESCBC) Encrypt(text []byte) ([]byte, error) {
+	if err := checkIV(a.iv, aes.BlockSize); err != nil {
+		return nil, err
+	}
+	r1, err := a.aesEncrypt(text, a.key1)
+	if err != nil {
+		return nil, err
+	}
+	r2, err := a.aesEncrypt(r1, a.key2)
+	if err != nil {
+		return nil, err
+	}
+	return encodeHex(r2), nil
 }
 
-func (a *AESCBC) Decrypt(hexStr string) string {
-	data, _ := hex.DecodeString(hexStr)
-	r1 := a.aesDecrypt(data[16:], a.key2)
-	r2 := a.aesDecrypt(r1[16:], a.key1)
+func (a *AESCBC) Decrypt(hexStr []byte) ([]byte, error) {
+	if err := checkIV(a.iv, aes.BlockSize); err != nil {
+		return nil, err
+	}
+	data, err := decodeHex(hexStr)
+	if err != nil {
+		return nil, err
+	}
+	r1, err := a.aesDecrypt(data, a.key2)
+	if err != nil {
+		return nil, err
+	}
+	r2, err := a.aesDecrypt(r1, a.key1)
+	if err != nil {
+		return nil, err
+	}
 	// Remove trailing zeros
-	r2 = bytes.TrimRight(r2, "\x00")
-	return string(r2)
+	return trimZeros(r2), nil
 }
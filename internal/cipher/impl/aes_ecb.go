@@ -1,10 +1,7 @@
 package cipher
 
 import (
-	"bytes"
 	"crypto/aes"
-	"encoding/hex"
-	"strings"
 )
 
 // AESECB implements AES ECB mode encryption/decryption
@@ -21,15 +18,14 @@ func NewAESECB(key1, key2 []byte) *AESECB {
 	}
 }
 
-func (a *AESECB) aesEncryptECB(data, key []byte) []byte {
+func (a *AESECB) aesEncryptECB(data, key []byte) ([]byte, error) {
 	// Pad data to block size
-	paddedData := data
-	if len(data)%aes.BlockSize != 0 {
-		paddedData = make([]byte, (len(data)/aes.BlockSize+1)*aes.BlockSize)
-		copy(paddedData, data)
-	}
+	paddedData := zeroPad(data, aes.BlockSize)
 
-	block, _ := aes.NewCipher(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
 	ciphertext := make([]byte, len(paddedData))
 
 	// ECB mode - encrypt each block independently
@@ -37,11 +33,18 @@ func (a *AESECB) aesEncryptECB(data, key []byte) []byte {
 		block.Encrypt(ciphertext[i:i+aes.BlockSize], paddedData[i:i+aes.BlockSize])
 	}
 
-	return ciphertext
+	return ciphertext, nil
 }
 
-func (a *AESECB) aesDecryptECB(data, key []byte) []byte {
-	block, _ := aes.NewCipher(key)
+func (a *AESECB) aesDecryptECB(data, key []byte) ([]byte, error) {
+	if err := checkBlocks(data, aes.BlockSize, 0); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
 	plaintext := make([]byte, len(data))
 
 	// ECB mode - decrypt each block independently
@@ -49,20 +52,34 @@ func (a *AESECB) aesDecryptECB(data, key []byte) []byte {
 		block.Decrypt(plaintext[i:i+aes.BlockSize], data[i:i+aes.BlockSize])
 	}
 
-	return plaintext
+	return plaintext, nil
 }
 
-func (a *AESECB) Encrypt(text string) string {
-	r1 := a.aesEncryptECB([]byte(text), a.key1)
-	r2 := a.aesEncryptECB(r1, a.key2)
-	return strings.ToUpper(hex.EncodeToString(r2))
+func (a *AESECB) Encrypt(text []byte) ([]byte, error) {
+	r1, err := a.aesEncryptECB(text, a.key1)
+	if err != nil {
+		return nil, err
+	}
+	r2, err := a.aesEncryptECB(r1, a.key2)
+	if err != nil {
+		return nil, err
+	}
+	return encodeHex(r2), nil
 }
 
-func (a *AESECB) Decrypt(hexStr string) string {
-	data, _ := hex.DecodeString(hexStr)
-	r1 := a.aesDecryptECB(data, a.key2)
-	r2 := a.aesDecryptECB(r1, a.key1)
+func (a *AESECB) Decrypt(hexStr []byte) ([]byte, error) {
+	data, err := decodeHex(hexStr)
+	if err != nil {
+		return nil, err
+	}
+	r1, err := a.aesDecryptECB(data, a.key2)
+	if err != nil {
+		return nil, err
+	}
+	r2, err := a.aesDecryptECB(r1, a.key1)
+	if err != nil {
+		return nil, err
+	}
 	// Remove trailing zeros
-	r2 = bytes.TrimRight(r2, "\x00")
-	return string(r2)
+	return trimZeros(r2), nil
 }
@@ -0,0 +1,123 @@
+package cipher
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// blockCipher is the interface every cipher in this package implements
+type blockCipher interface {
+	Encrypt(text []byte) ([]byte, error)
+	Decrypt(hexStr []byte) ([]byte, error)
+}
+
+var (
+	key16 = mustHex("000102030405060708090a0b0c0d0e0f")
+	key24 = mustHex("000102030405060708090a0b0c0d0e0f1011121314151617")
+	iv16  = mustHex("0f0e0d0c0b0a09080706050403020100")
+	iv8   = mustHex("0706050403020100")
+
+	xteaKey1 = []uint32{1, 2, 3, 4}
+	xteaKey2 = []uint32{5, 6, 7, 8}
+	xteaKey3 = []uint32{9, 10, 11, 12}
+	xteaIV   = []uint32{13, 14}
+)
+
+// zeroPadded ciphers carry no padding of their own, so their errors are about
+// the ciphertext length and encoding only
+var zeroPadded = []struct {
+	name      string
+	cipher    blockCipher
+	blockSize int
+	minBlocks int // Blocks in front of the data, the IV prepended by AES-CBC
+}{
+	{"AES-CBC", NewAESCBC(key16, iv16, iv16), 16, 1},
+	{"AES-ECB", NewAESECB(key16, iv16), 16, 0},
+	{"DESede-CBC", NewDESedeCBC(key24, key24, iv8), 8, 0},
+	{"DESede-ECB", NewDESedeECB(key24, key24), 8, 0},
+	{"ModXTEA", NewModXTEA(xteaKey1, xteaKey2, xteaKey3), 8, 0},
+	{"ModXTEA-IV", NewModXTEAIV(xteaKey1, xteaKey2, xteaKey3, xteaIV), 8, 0},
+}
+
+func TestZeroPaddedRoundTrip(t *testing.T) {
+	for _, tt := range zeroPadded {
+		for _, n := range []int{0, 1, 7, 8, 16, 33} {
+			plain := bytes.Repeat([]byte{'x'}, n)
+			enc, err := tt.cipher.Encrypt(plain)
+			if err != nil {
+				t.Fatalf("%s: Encrypt %d bytes: %v", tt.name, n, err)
+			}
+			dec, err := tt.cipher.Decrypt(enc)
+			if err != nil || !bytes.Equal(dec, plain) {
+				t.Errorf("%s: Decrypt %d bytes = %q, %v", tt.name, n, dec, err)
+			}
+		}
+	}
+}
+
+func TestZeroPaddedDecryptErrors(t *testing.T) {
+	for _, tt := range zeroPadded {
+		tests := []struct {
+			name string
+			data []byte
+			want error
+		}{
+			{"partial block", encodeHex(make([]byte, (tt.minBlocks+1)*tt.blockSize+3)), ErrInvalidLength},
+			{"odd byte", encodeHex(make([]byte, 1)), ErrInvalidLength},
+			{"bad hex", []byte("zz"), ErrInvalidHex},
+			{"odd hex", []byte("abc"), ErrInvalidHex},
+		}
+		if tt.minBlocks > 0 {
+			tests = append(tests, struct {
+				name string
+				data []byte
+				want error
+			}{"shorter than the iv", encodeHex(make([]byte, tt.minBlocks*tt.blockSize-tt.blockSize/2)), ErrInvalidLength})
+		}
+		for _, e := range tests {
+			if _, err := tt.cipher.Decrypt(e.data); !errors.Is(err, e.want) {
+				t.Errorf("%s: Decrypt %s = %v, want %v", tt.name, e.name, err, e.want)
+			}
+		}
+	}
+}
+
+// TestAESCBCInnerLength checks the length of the inner layer, which is only
+// known after the outer one is decrypted
+func TestAESCBCInnerLength(t *testing.T) {
+	c := NewAESCBC(key16, iv16, iv16)
+	// An outer layer holding only its IV decrypts to an inner layer without one
+	outer, err := c.aesEncrypt(nil, c.key2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Decrypt(encodeHex(outer)); !errors.Is(err, ErrInvalidLength) {
+		t.Errorf("Decrypt = %v, want ErrInvalidLength", err)
+	}
+}
+
+func TestBadKeyMaterial(t *testing.T) {
+	tests := []struct {
+		name   string
+		cipher blockCipher
+	}{
+		{"AES-CBC short iv", NewAESCBC(key16, key16, iv8)},
+		{"AES-CBC bad key", NewAESCBC(key16[:5], key16, iv16)},
+		{"AES-ECB bad key", NewAESECB(key16, key16[:5])},
+		{"DESede-CBC short iv", NewDESedeCBC(key24, key24, iv16[:4])},
+		{"DESede-CBC bad key", NewDESedeCBC(key16[:5], key24, iv8)},
+		{"DESede-ECB bad key", NewDESedeECB(key24, key16[:5])},
+		{"ModXTEA short key", NewModXTEA(xteaKey1, xteaKey2[:3], xteaKey3)},
+		{"ModXTEA-IV short key", NewModXTEAIV(xteaKey1[:1], xteaKey2, xteaKey3, xteaIV)},
+		{"ModXTEA-IV short iv", NewModXTEAIV(xteaKey1, xteaKey2, xteaKey3, xteaIV[:1])},
+	}
+	for _, tt := range tests {
+		if _, err := tt.cipher.Encrypt([]byte("text")); err == nil {
+			t.Errorf("%s: Encrypt succeeded", tt.name)
+		}
+		if _, err := tt.cipher.Decrypt(encodeHex(make([]byte, 32))); err == nil {
+			t.Errorf("%s: Decrypt succeeded", tt.name)
+		}
+	}
+}
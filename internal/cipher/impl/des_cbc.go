@@ -1,11 +1,8 @@
 package cipher
 
 import (
-	"bytes"
 	"crypto/cipher"
 	"crypto/des"
-	"encoding/hex"
-	"strings"
 )
 
 // DESedeCBC implements Triple DES CBC mode
@@ -24,42 +21,67 @@ func NewDESedeCBC(key1, key2, iv []byte) *DESedeCBC {
 	}
 }
 
-func (d *DESedeCBC) desEncrypt(data, key []byte) []byte {
+func (d *DESedeCBC) desEncrypt(data, key []byte) ([]byte, error) {
 	// Pad data to block size
-	paddedData := data
-	blockSize := des.BlockSize
-	if len(data)%blockSize != 0 {
-		paddedData = make([]byte, (len(data)/blockSize+1)*blockSize)
-		copy(paddedData, data)
-	}
+	paddedData := zeroPad(data, des.BlockSize)
 
-	block, _ := des.NewTripleDESCipher(key)
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		return nil, err
+	}
 	ciphertext := make([]byte, len(paddedData))
 	mode := cipher.NewCBCEncrypter(block, d.iv)
 	mode.CryptBlocks(ciphertext, paddedData)
 
-	return ciphertext
+	return ciphertext, nil
 }
 
-func (d *DESedeCBC) desDecrypt(data, key []byte) []byte {
-	block, _ := des.NewTripleDESCipher(key)
+func (d *DESedeCBC) desDecrypt(data, key []byte) ([]byte, error) {
+	if err := checkBlocks(data, des.BlockSize, 0); err != nil {
+		return nil, err
+	}
+
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		return nil, err
+	}
 	plaintext := make([]byte, len(data))
 	mode := cipher.NewCBCDecrypter(block, d.iv)
 	mode.CryptBlocks(plaintext, data)
-	return plaintext
+	return plaintext, nil
 }
 
-func (d *DESedeCBC) Encrypt(text string) string {
-	r1 := d.desEncrypt([]byte(text), d.key1)
-	r2 := d.desEncrypt(r1, d.key2)
-	return strings.ToUpper(hex.EncodeToString(r2))
+func (d *DESedeCBC) Encrypt(text []byte) ([]byte, error) {
+	if err := checkIV(d.iv, des.BlockSize); err != nil {
+		return nil, err
+	}
+	r1, err := d.desEncrypt(text, d.key1)
+	if err != nil {
+		return nil, err
+	}
+	r2, err := d.desEncrypt(r1, d.key2)
+	if err != nil {
+		return nil, err
+	}
+	return encodeHex(r2), nil
 }
 
-func (d *DESedeCBC) Decrypt(hexStr string) string {
-	data, _ := hex.DecodeString(hexStr)
-	r1 := d.desDecrypt(data, d.key2)
-	r2 := d.desDecrypt(r1, d.key1)
+func (d *DESedeCBC) Decrypt(hexStr []byte) ([]byte, error) {
+	if err := checkIV(d.iv, des.BlockSize); err != nil {
+		return nil, err
+	}
+	data, err := decodeHex(hexStr)
+	if err != nil {
+		return nil, err
+	}
+	r1, err := d.desDecrypt(data, d.key2)
+	if err != nil {
+		return nil, err
+	}
+	r2, err := d.desDecrypt(r1, d.key1)
+	if err != nil {
+		return nil, err
+	}
 	// Remove trailing zeros
-	r2 = bytes.TrimRight(r2, "\x00")
-	return string(r2)
+	return trimZeros(r2), nil
 }
@@ -1,10 +1,7 @@
 package cipher
 
 import (
-	"bytes"
 	"crypto/des"
-	"encoding/hex"
-	"strings"
 )
 
 // DESedeECB implements Triple DES ECB mode
@@ -21,16 +18,15 @@ func NewDESedeECB(key1, key2 []byte) *DESedeECB {
 	}
 }
 
-func (d *DESedeECB) desEncryptECB(data, key []byte) []byte {
+func (d *DESedeECB) desEncryptECB(data, key []byte) ([]byte, error) {
 	// Pad data to block size
-	paddedData := data
 	blockSize := des.BlockSize
-	if len(data)%blockSize != 0 {
-		paddedData = make([]byte, (len(data)/blockSize+1)*blockSize)
-		copy(paddedData, data)
-	}
+	paddedData := zeroPad(data, blockSize)
 
-	block, _ := des.NewTripleDESCipher(key)
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		return nil, err
+	}
 	ciphertext := make([]byte, len(paddedData))
 
 	// ECB mode - encrypt each block independently
@@ -38,12 +34,19 @@ func (d *DESedeECB) desEncryptECB(data, key []byte) []byte {
 		block.Encrypt(ciphertext[i:i+blockSize], paddedData[i:i+blockSize])
 	}
 
-	return ciphertext
+	return ciphertext, nil
 }
 
-func (d *DESedeECB) desDecryptECB(data, key []byte) []byte {
+func (d *DESedeECB) desDecryptECB(data, key []byte) ([]byte, error) {
 	blockSize := des.BlockSize
-	block, _ := des.NewTripleDESCipher(key)
+	if err := checkBlocks(data, blockSize, 0); err != nil {
+		return nil, err
+	}
+
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		return nil, err
+	}
 	plaintext := make([]byte, len(data))
 
 	// ECB mode - decrypt each block independently
@@ -51,20 +54,34 @@ func (d *DESedeECB) desDecryptECB(data, key []byte) []byte {
 		block.Decrypt(plaintext[i:i+blockSize], data[i:i+blockSize])
 	}
 
-	return plaintext
+	return plaintext, nil
 }
 
-func (d *DESedeECB) Encrypt(text string) string {
-	r1 := d.desEncryptECB([]byte(text), d.key1)
-	r2 := d.desEncryptECB(r1, d.key2)
-	return strings.ToUpper(hex.EncodeToString(r2))
+func (d *DESedeECB) Encrypt(text []byte) ([]byte, error) {
+	r1, err := d.desEncryptECB(text, d.key1)
+	if err != nil {
+		return nil, err
+	}
+	r2, err := d.desEncryptECB(r1, d.key2)
+	if err != nil {
+		return nil, err
+	}
+	return encodeHex(r2), nil
 }
 
-func (d *DESedeECB) Decrypt(hexStr string) string {
-	data, _ := hex.DecodeString(hexStr)
-	r1 := d.desDecryptECB(data, d.key2)
-	r2 := d.desDecryptECB(r1, d.key1)
+func (d *DESedeECB) Decrypt(hexStr []byte) ([]byte, error) {
+	data, err := decodeHex(hexStr)
+	if err != nil {
+		return nil, err
+	}
+	r1, err := d.desDecryptECB(data, d.key2)
+	if err != nil {
+		return nil, err
+	}
+	r2, err := d.desDecryptECB(r1, d.key1)
+	if err != nil {
+		return nil, err
+	}
 	// Remove trailing zeros
-	r2 = bytes.TrimRight(r2, "\x00")
-	return string(r2)
+	return trimZeros(r2), nil
 }
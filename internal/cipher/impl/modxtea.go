@@ -2,8 +2,7 @@ package cipher
 
 import (
 	"encoding/binary"
-	"encoding/hex"
-	"strings"
+	"fmt"
 )
 
 // ModXTEA implements a modified XTEA algorithm
@@ -44,14 +43,22 @@ func (m *ModXTEA) decryptBlock(v0, v1 uint32, key []uint32) (uint32, uint32) {
 	return v0, v1
 }
 
-func (m *ModXTEA) Encrypt(text string) string {
-	data := []byte(text)
-	// Pad to 8-byte boundary
-	if len(data)%8 != 0 {
-		padded := make([]byte, (len(data)/8+1)*8)
-		copy(padded, data)
-		data = padded
+// checkXTEAKeys ensures every key holds the four words XTEA indexes into
+func checkXTEAKeys(keys ...[]uint32) error {
+	for _, key := range keys {
+		if len(key) != 4 {
+			return fmt.Errorf("cipher: invalid XTEA key size %d", len(key))
+		}
 	}
+	return nil
+}
+
+func (m *ModXTEA) Encrypt(text []byte) ([]byte, error) {
+	if err := checkXTEAKeys(m.key1, m.key2, m.key3); err != nil {
+		return nil, err
+	}
+	// Pad to 8-byte boundary
+	data := zeroPad(text, 8)
 	
 	result := make([]byte, len(data))
 	
@@ -68,11 +75,20 @@ func (m *ModXTEA) Encrypt(text string) string {
 		binary.BigEndian.PutUint32(result[i+4:i+8], v1)
 	}
 	
-	return strings.ToUpper(hex.EncodeToString(result))
+	return encodeHex(result), nil
 }
 
-func (m *ModXTEA) Decrypt(hexStr string) string {
-	data, _ := hex.DecodeString(hexStr)
+func (m *ModXTEA) Decrypt(hexStr []byte) ([]byte, error) {
+	if err := checkXTEAKeys(m.key1, m.key2, m.key3); err != nil {
+		return nil, err
+	}
+	data, err := decodeHex(hexStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBlocks(data, 8, 0); err != nil {
+		return nil, err
+	}
 	result := make([]byte, len(data))
 	
 	for i := 0; i < len(data); i += 8 {
@@ -89,9 +105,5 @@ func (m *ModXTEA) Decrypt(hexStr string) string {
 	}
 	
 	// Remove trailing zeros
-	for len(result) > 0 && result[len(result)-1] == 0 {
-		result = result[:len(result)-1]
-	}
-	
-	return string(result)
+	return trimZeros(result), nil
 }
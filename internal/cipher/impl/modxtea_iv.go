@@ -2,8 +2,7 @@ package cipher
 
 import (
 	"encoding/binary"
-	"encoding/hex"
-	"strings"
+	"fmt"
 )
 
 // ModXTEAIV implements a modified XTEA algorithm with IV
@@ -45,14 +44,19 @@ func (m *ModXTEAIV) decryptBlock(v0, v1 uint32, key []uint32) (uint32, uint32) {
 	return v0, v1
 }
 
-func (m *ModXTEAIV) Encrypt(text string) string {
-	data := []byte(text)
-	// Pad to 8-byte boundary
-	if len(data)%8 != 0 {
-		padded := make([]byte, (len(data)/8+1)*8)
-		copy(padded, data)
-		data = padded
+func (m *ModXTEAIV) checkKeys() error {
+	if len(m.iv) != 2 {
+		return fmt.Errorf("cipher: invalid XTEA IV size %d", len(m.iv))
 	}
+	return checkXTEAKeys(m.key1, m.key2, m.key3)
+}
+
+func (m *ModXTEAIV) Encrypt(text []byte) ([]byte, error) {
+	if err := m.checkKeys(); err != nil {
+		return nil, err
+	}
+	// Pad to 8-byte boundary
+	data := zeroPad(text, 8)
 	
 	result := make([]byte, len(data))
 	prevV0, prevV1 := m.iv[0], m.iv[1]
@@ -72,11 +76,20 @@ func (m *ModXTEAIV) Encrypt(text string) string {
 		prevV0, prevV1 = v0, v1
 	}
 	
-	return strings.ToUpper(hex.EncodeToString(result))
+	return encodeHex(result), nil
 }
 
-func (m *ModXTEAIV) Decrypt(hexStr string) string {
-	data, _ := hex.DecodeString(hexStr)
+func (m *ModXTEAIV) Decrypt(hexStr []byte) ([]byte, error) {
+	if err := m.checkKeys(); err != nil {
+		return nil, err
+	}
+	data, err := decodeHex(hexStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBlocks(data, 8, 0); err != nil {
+		return nil, err
+	}
 	result := make([]byte, len(data))
 	prevV0, prevV1 := m.iv[0], m.iv[1]
 	
@@ -101,9 +114,5 @@ func (m *ModXTEAIV) Decrypt(hexStr string) string {
 	}
 	
 	// Remove trailing zeros
-	for len(result) > 0 && result[len(result)-1] == 0 {
-		result = result[:len(result)-1]
-	}
-	
-	return string(result)
+	return trimZeros(result), nil
 }
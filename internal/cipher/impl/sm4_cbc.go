@@ -1,12 +1,10 @@
 package cipher
 
 import (
-	"encoding/hex"
-	"strings"
+	"crypto/cipher"
 )
 
-// SM4CBC implements SM4 CBC mode encryption/decryption
-// This is a placeholder - in production, use a proper SM4 library
+// SM4CBC implements SM4 CBC mode encryption/decryption with PKCS5 padding
 type SM4CBC struct {
 	key []byte
 	iv  []byte
@@ -17,15 +15,42 @@ func NewSM4CBC(key, iv []byte) *SM4CBC {
 	return &SM4CBC{key: key, iv: iv}
 }
 
-func (s *SM4CBC) Encrypt(text string) string {
-	// TODO: Implement proper SM4 encryption
-	// For now, return a placeholder
-	// In production, use a Go SM4 library like github.com/tjfoc/gmsm/sm4
-	return strings.ToUpper(hex.EncodeToString([]byte(text)))
+func (s *SM4CBC) Encrypt(text []byte) ([]byte, error) {
+	if err := checkIV(s.iv, sm4BlockSize); err != nil {
+		return nil, err
+	}
+	paddedData := pkcs5Pad(text, sm4BlockSize)
+
+	block, err := newSM4Cipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := make([]byte, len(paddedData))
+	mode := cipher.NewCBCEncrypter(block, s.iv)
+	mode.CryptBlocks(ciphertext, paddedData)
+
+	return encodeHex(ciphertext), nil
 }
 
-func (s *SM4CBC) Decrypt(hexStr string) string {
-	// TODO: Implement proper SM4 decryption
-	data, _ := hex.DecodeString(hexStr)
-	return string(data)
+func (s *SM4CBC) Decrypt(hexStr []byte) ([]byte, error) {
+	if err := checkIV(s.iv, sm4BlockSize); err != nil {
+		return nil, err
+	}
+	data, err := decodeHex(hexStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBlocks(data, sm4BlockSize, sm4BlockSize); err != nil {
+		return nil, err
+	}
+
+	block, err := newSM4Cipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(data))
+	mode := cipher.NewCBCDecrypter(block, s.iv)
+	mode.CryptBlocks(plaintext, data)
+
+	return pkcs5Unpad(plaintext, sm4BlockSize)
 }
@@ -1,12 +1,6 @@
 package cipher
 
-import (
-	"encoding/hex"
-	"strings"
-)
-
-// SM4ECB implements SM4 ECB mode encryption/decryption
-// This is a placeholder - in production, use a proper SM4 library
+// SM4ECB implements SM4 ECB mode encryption/decryption with zero padding, like the other NoPadding ECB ciphers
 type SM4ECB struct {
 	key []byte
 }
@@ -16,15 +10,44 @@ func NewSM4ECB(key []byte) *SM4ECB {
 	return &SM4ECB{key: key}
 }
 
-func (s *SM4ECB) Encrypt(text string) string {
-	// TODO: Implement proper SM4 encryption
-	// For now, return a placeholder
-	// In production, use a Go SM4 library like github.com/tjfoc/gmsm/sm4
-	return strings.ToUpper(hex.EncodeToString([]byte(text)))
+func (s *SM4ECB) Encrypt(text []byte) ([]byte, error) {
+	// Pad data to block size
+	paddedData := zeroPad(text, sm4BlockSize)
+
+	block, err := newSM4Cipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := make([]byte, len(paddedData))
+
+	// ECB mode - encrypt each block independently
+	for i := 0; i < len(paddedData); i += sm4BlockSize {
+		block.Encrypt(ciphertext[i:i+sm4BlockSize], paddedData[i:i+sm4BlockSize])
+	}
+
+	return encodeHex(ciphertext), nil
 }
 
-func (s *SM4ECB) Decrypt(hexStr string) string {
-	// TODO: Implement proper SM4 decryption
-	data, _ := hex.DecodeString(hexStr)
-	return string(data)
+func (s *SM4ECB) Decrypt(hexStr []byte) ([]byte, error) {
+	data, err := decodeHex(hexStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBlocks(data, sm4BlockSize, sm4BlockSize); err != nil {
+		return nil, err
+	}
+
+	block, err := newSM4Cipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(data))
+
+	// ECB mode - decrypt each block independently
+	for i := 0; i < len(data); i += sm4BlockSize {
+		block.Decrypt(plaintext[i:i+sm4BlockSize], data[i:i+sm4BlockSize])
+	}
+
+	// Remove trailing zeros
+	return trimZeros(plaintext), nil
 }
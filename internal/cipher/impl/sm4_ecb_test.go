@@ -0,0 +1,67 @@
+package cipher
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSM4ECBVector(t *testing.T) {
+	// A full block needs no padding, the output is the plain block cipher output
+	c := NewSM4ECB(sm4Key)
+	enc, err := c.Encrypt(sm4Plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := encodeHex(sm4Cipher1); !bytes.Equal(enc, want) {
+		t.Fatalf("Encrypt() = %s, want %s", enc, want)
+	}
+
+	dec, err := c.Decrypt(enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec, sm4Plain) {
+		t.Fatalf("Decrypt() = %x, want %x", dec, sm4Plain)
+	}
+}
+
+func TestSM4ECBRoundTrip(t *testing.T) {
+	c := NewSM4ECB(sm4Key)
+	for _, text := range []string{"a", "<request>", "0123456789abcdef", "<?xml version=\"1.0\" encoding=\"utf-8\"?><request></request>"} {
+		enc, err := c.Encrypt([]byte(text))
+		if err != nil {
+			t.Fatalf("encrypt %q: %v", text, err)
+		}
+		if len(enc)%(2*sm4BlockSize) != 0 {
+			t.Fatalf("encrypt %q: %d hex chars is not whole blocks", text, len(enc))
+		}
+		dec, err := c.Decrypt(enc)
+		if err != nil {
+			t.Fatalf("decrypt %q: %v", text, err)
+		}
+		if string(dec) != text {
+			t.Fatalf("round trip of %q = %q", text, dec)
+		}
+	}
+}
+
+func TestSM4ECBDecryptErrors(t *testing.T) {
+	c := NewSM4ECB(sm4Key)
+	tests := []struct {
+		name  string
+		input []byte
+		want  error
+	}{
+		{"empty", nil, ErrInvalidLength},
+		{"partial block", encodeHex(make([]byte, 20)), ErrInvalidLength},
+		{"bad hex", []byte("xyz0"), ErrInvalidHex},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Decrypt(tt.input); !errors.Is(err, tt.want) {
+				t.Fatalf("Decrypt() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
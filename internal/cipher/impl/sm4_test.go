@@ -0,0 +1,127 @@
+package cipher
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// GB/T 32907-2016 appendix A
+var (
+	sm4Key       = mustHex("0123456789abcdeffedcba9876543210")
+	sm4Plain     = sm4Key
+	sm4Cipher1   = mustHex("681edf34d206965e86b3e94f536e4246")
+	sm4Cipher1M  = mustHex("595298c7c6fd271f0402f804c33d3f66")
+	sm4TestIV    = mustHex("000102030405060708090a0b0c0d0e0f")
+	sm4Iteration = 1000000
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestSM4Block(t *testing.T) {
+	c, err := newSM4Cipher(sm4Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := make([]byte, sm4BlockSize)
+	c.Encrypt(dst, sm4Plain)
+	if !bytes.Equal(dst, sm4Cipher1) {
+		t.Fatalf("encrypt = %x, want %x", dst, sm4Cipher1)
+	}
+	c.Decrypt(dst, dst)
+	if !bytes.Equal(dst, sm4Plain) {
+		t.Fatalf("decrypt = %x, want %x", dst, sm4Plain)
+	}
+}
+
+func TestSM4BlockIterated(t *testing.T) {
+	if testing.Short() {
+		t.Skip("1,000,000 iterations")
+	}
+	c, err := newSM4Cipher(sm4Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := append([]byte{}, sm4Plain...)
+	for i := 0; i < sm4Iteration; i++ {
+		c.Encrypt(block, block)
+	}
+	if !bytes.Equal(block, sm4Cipher1M) {
+		t.Fatalf("encrypt x%d = %x, want %x", sm4Iteration, block, sm4Cipher1M)
+	}
+}
+
+func TestSM4InvalidKey(t *testing.T) {
+	if _, err := newSM4Cipher(make([]byte, 15)); err == nil {
+		t.Fatal("expected an error for a 15 byte key")
+	}
+}
+
+func TestSM4CBCRoundTrip(t *testing.T) {
+	c := NewSM4CBC(sm4Key, sm4TestIV)
+	for _, n := range []int{0, 1, 15, 16, 17, 100} {
+		text := bytes.Repeat([]byte{'a'}, n)
+		enc, err := c.Encrypt(text)
+		if err != nil {
+			t.Fatalf("encrypt %d bytes: %v", n, err)
+		}
+		dec, err := c.Decrypt(enc)
+		if err != nil {
+			t.Fatalf("decrypt %d bytes: %v", n, err)
+		}
+		if !bytes.Equal(dec, text) {
+			t.Fatalf("round trip of %d bytes = %q", n, dec)
+		}
+	}
+}
+
+func TestSM4CBCFirstBlock(t *testing.T) {
+	// With a zero IV the first CBC block is the plain block cipher output
+	enc, err := NewSM4CBC(sm4Key, make([]byte, sm4BlockSize)).Encrypt(sm4Plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := encodeHex(sm4Cipher1); !bytes.Equal(enc[:32], want) {
+		t.Fatalf("first block = %s, want %s", enc[:32], want)
+	}
+}
+
+func TestSM4CBCDecryptErrors(t *testing.T) {
+	c := NewSM4CBC(sm4Key, sm4TestIV)
+
+	// Encrypting the IV gives a block that decrypts to zeros, a 0x00 pad length is invalid
+	block, err := newSM4Cipher(sm4Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badPadding := make([]byte, sm4BlockSize)
+	block.Encrypt(badPadding, sm4TestIV)
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  error
+	}{
+		{"empty", nil, ErrInvalidLength},
+		{"short", encodeHex(make([]byte, 8)), ErrInvalidLength},
+		{"partial block", encodeHex(make([]byte, 20)), ErrInvalidLength},
+		{"bad padding", encodeHex(badPadding), ErrInvalidPadding},
+		{"bad hex", []byte("zz"), ErrInvalidHex},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := c.Decrypt(tt.input); !errors.Is(err, tt.want) {
+				t.Fatalf("Decrypt() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
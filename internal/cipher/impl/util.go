@@ -0,0 +1,86 @@
+package cipher
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidHex is returned when the ciphertext is not valid hex
+	ErrInvalidHex = errors.New("cipher: ciphertext is not valid hex")
+	// ErrInvalidLength is returned when the ciphertext length does not fit the block layout
+	ErrInvalidLength = errors.New("cipher: invalid ciphertext length")
+	// ErrInvalidPadding is returned when PKCS5 padding is malformed after decryption
+	ErrInvalidPadding = errors.New("cipher: invalid padding")
+)
+
+// encodeHex encodes data as upper-case hex, the form the portal expects
+func encodeHex(data []byte) []byte {
+	return bytes.ToUpper([]byte(hex.EncodeToString(data)))
+}
+
+// decodeHex decodes hex ciphertext, surrounding whitespace is ignored
+func decodeHex(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	result := make([]byte, hex.DecodedLen(len(data)))
+	if _, err := hex.Decode(result, data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHex, err)
+	}
+	return result, nil
+}
+
+// checkBlocks ensures data is at least minLen bytes and a multiple of blockSize
+func checkBlocks(data []byte, blockSize, minLen int) error {
+	if len(data) < minLen || len(data)%blockSize != 0 {
+		return fmt.Errorf("%w: %d bytes (block size %d)", ErrInvalidLength, len(data), blockSize)
+	}
+	return nil
+}
+
+// zeroPad pads data with zeros to a multiple of blockSize, as NoPadding ciphers expect
+func zeroPad(data []byte, blockSize int) []byte {
+	if len(data)%blockSize == 0 {
+		return data
+	}
+	padded := make([]byte, (len(data)/blockSize+1)*blockSize)
+	copy(padded, data)
+	return padded
+}
+
+// trimZeros removes the trailing zeros added by zeroPad
+func trimZeros(data []byte) []byte {
+	return bytes.TrimRight(data, "\x00")
+}
+
+// pkcs5Pad pads data to a multiple of blockSize as PKCS#5/PKCS#7
+func pkcs5Pad(data []byte, blockSize int) []byte {
+	n := blockSize - len(data)%blockSize
+	return append(append([]byte{}, data...), bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+// pkcs5Unpad strips and verifies PKCS#5/PKCS#7 padding
+func pkcs5Unpad(data []byte, blockSize int) ([]byte, error) {
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return nil, ErrInvalidPadding
+	}
+	n := int(data[len(data)-1])
+	if n == 0 || n > blockSize {
+		return nil, ErrInvalidPadding
+	}
+	for _, b := range data[len(data)-n:] {
+		if int(b) != n {
+			return nil, ErrInvalidPadding
+		}
+	}
+	return data[:len(data)-n], nil
+}
+
+// checkIV ensures the IV matches the cipher block size
+func checkIV(iv []byte, blockSize int) error {
+	if len(iv) != blockSize {
+		return fmt.Errorf("cipher: invalid IV size %d (block size %d)", len(iv), blockSize)
+	}
+	return nil
+}
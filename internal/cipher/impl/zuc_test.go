@@ -0,0 +1,64 @@
+package cipher
+
+import (
+	"bytes"
+	"testing"
+)
+
+// GM/T 0001-2012 / 3GPP ZUC specification test sets, the first two keystream words
+var zucVectors = []struct {
+	name   string
+	key    string
+	iv     string
+	stream string
+}{
+	{"zeros", "00000000000000000000000000000000", "00000000000000000000000000000000", "27bede74018082da"},
+	{"ones", "ffffffffffffffffffffffffffffffff", "ffffffffffffffffffffffffffffffff", "0657cfa07096398b"},
+	{"random", "3d4c4be96a82fdaeb58f641db17b455b", "84319aa8de6915ca1f6bda6bfbd8c766", "14f1c2723279c419"},
+}
+
+func TestZUCKeystream(t *testing.T) {
+	for _, v := range zucVectors {
+		t.Run(v.name, func(t *testing.T) {
+			// Encrypting zeros yields the keystream itself
+			enc, err := NewZUC(mustHex(v.key), mustHex(v.iv)).Encrypt(make([]byte, 8))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := encodeHex(mustHex(v.stream)); !bytes.Equal(enc, want) {
+				t.Fatalf("keystream = %s, want %s", enc, want)
+			}
+		})
+	}
+}
+
+func TestZUCPartialWord(t *testing.T) {
+	v := zucVectors[2]
+	c := NewZUC(mustHex(v.key), mustHex(v.iv))
+	for _, n := range []int{1, 3, 5, 7, 13} {
+		text := bytes.Repeat([]byte{'x'}, n)
+		enc, err := c.Encrypt(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(enc) != 2*n {
+			t.Fatalf("%d bytes encrypted to %d hex chars", n, len(enc))
+		}
+		dec, err := c.Decrypt(enc)
+		if err != nil {
+			t.Fatalf("decrypt %d bytes: %v", n, err)
+		}
+		if !bytes.Equal(dec, text) {
+			t.Fatalf("round trip of %d bytes = %q", n, dec)
+		}
+	}
+
+	// The tail of a partial word uses the leading bytes of the next keystream word
+	enc, err := c.Encrypt(make([]byte, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := encodeHex(mustHex(v.stream)[:7]); !bytes.Equal(enc, want) {
+		t.Fatalf("7 byte keystream = %s, want %s", enc, want)
+	}
+}
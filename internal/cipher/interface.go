@@ -1,7 +1,10 @@
 package cipher
 
-// CipherInterface defines the interface for encryption/decryption
+// CipherInterface defines the interface for encryption/decryption.
+// Encrypt returns the upper-case hex encoded ciphertext and Decrypt
+// accepts the same encoding; both must return an error instead of
+// panicking on malformed input.
 type CipherInterface interface {
-	Encrypt(text string) string
-	Decrypt(hex string) string
+	Encrypt(text []byte) ([]byte, error)
+	Decrypt(hex []byte) ([]byte, error)
 }
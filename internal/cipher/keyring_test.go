@@ -0,0 +1,90 @@
+package cipher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// sm4Entry is a valid keyring entry
+const sm4Entry = `{"name": "SM4 test", "type": "sm4-cbc", "keys": ["282f29256f3c75486d4c2e515527222d"], "iv": "683c42515a463a5267777e6e6970485e"}`
+
+func writeKeyring(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadKeyring(t *testing.T) {
+	saveRegistry(t)
+	builtin := ListAlgorithms()[0]
+	path := writeKeyring(t, `{
+		"test-keyring-1": `+sm4Entry+`,
+		"TEST-KEYRING-2": {"type": "aes-ecb", "keys": ["000102030405060708090a0b0c0d0e0f", "0f0e0d0c0b0a09080706050403020100"]},
+		"`+builtin.ID+`": `+sm4Entry+`
+	}`)
+
+	n, err := LoadKeyring(path)
+	if err != nil || n != 3 {
+		t.Fatalf("LoadKeyring = %d, %v, want 3", n, err)
+	}
+	if algo, ok := Lookup("TEST-KEYRING-1"); !ok || algo.Name != "SM4 test" || algo.Type != "sm4-cbc" {
+		t.Errorf("Lookup = %+v, %v", algo, ok)
+	}
+	if algo, ok := Lookup("test-keyring-2"); !ok || algo.Name != "aes-ecb" {
+		t.Errorf("Lookup without a name = %+v, %v, want the type as name", algo, ok)
+	}
+	if algo, _ := Lookup(builtin.ID); algo.Name != "SM4 test" {
+		t.Errorf("built-in %s not overridden: %+v", builtin.ID, algo)
+	}
+
+	c, err := GetInstance("TEST-KEYRING-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := c.Encrypt([]byte("keyring"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dec, err := c.Decrypt(enc); err != nil || string(dec) != "keyring" {
+		t.Errorf("Decrypt = %q, %v", dec, err)
+	}
+}
+
+func TestLoadKeyringInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"not json", `{"a": `},
+		{"not an object", `[]`},
+		{"unknown type", `{"TEST-A": ` + sm4Entry + `, "TEST-B": {"type": "rot13", "keys": ["00"]}}`},
+		{"empty id", `{"TEST-A": ` + sm4Entry + `, " ": ` + sm4Entry + `}`},
+		{"duplicate id", `{"TEST-A": ` + sm4Entry + `, "TEST-A": ` + sm4Entry + `}`},
+		{"duplicate id in another case", `{"TEST-A": ` + sm4Entry + `, "test-a": ` + sm4Entry + `}`},
+		{"bad hex key", `{"TEST-A": ` + sm4Entry + `, "TEST-B": {"type": "sm4-ecb", "keys": ["zz"]}}`},
+		{"bad hex iv", `{"TEST-A": ` + sm4Entry + `, "TEST-B": {"type": "sm4-cbc", "keys": ["282f29256f3c75486d4c2e515527222d"], "iv": "zz"}}`},
+		{"missing iv", `{"TEST-A": ` + sm4Entry + `, "TEST-B": {"type": "sm4-cbc", "keys": ["282f29256f3c75486d4c2e515527222d"]}}`},
+		{"bad key size", `{"TEST-A": ` + sm4Entry + `, "TEST-B": {"type": "aes-ecb", "keys": ["0001", "0203"]}}`},
+		{"wrong key count", `{"TEST-A": ` + sm4Entry + `, "TEST-B": {"type": "aes-ecb", "keys": ["000102030405060708090a0b0c0d0e0f"]}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saveRegistry(t)
+			before := registeredIDs()
+			if n, err := LoadKeyring(writeKeyring(t, tt.content)); err == nil {
+				t.Fatalf("LoadKeyring loaded %d algorithms", n)
+			}
+			if got := registeredIDs(); got != before {
+				t.Errorf("a failed load changed the registry:\n%s\nwant:\n%s", got, before)
+			}
+		})
+	}
+
+	if _, err := LoadKeyring(filepath.Join(t.TempDir(), "missing.json")); !os.IsNotExist(err) {
+		t.Errorf("LoadKeyring of a missing file = %v", err)
+	}
+}
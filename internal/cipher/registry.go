@@ -0,0 +1,130 @@
+package cipher
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// KeyMaterial holds the keys and IV a cipher type is constructed from
+type KeyMaterial struct {
+	Keys [][]byte
+	IV   []byte
+}
+
+// KeyProvider supplies the key material of an algorithm
+type KeyProvider func() (*KeyMaterial, error)
+
+// Factory creates a cipher of one type from key material
+type Factory func(km *KeyMaterial) (CipherInterface, error)
+
+// Algorithm describes an algorithm the portal may negotiate via Algo-ID
+type Algorithm struct {
+	ID   string      // Algo-ID GUID sent by the portal
+	Name string      // Human readable name, e.g. "AES/CBC/NoPadding"
+	Type string      // Registered cipher type, e.g. "aes-cbc"
+	Keys KeyProvider // Key material for the cipher type
+}
+
+var (
+	registryMu sync.RWMutex
+	algorithms = make(map[string]Algorithm)
+)
+
+// RegisterType registers a cipher type that algorithms can refer to
+func RegisterType(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	types[strings.ToLower(name)] = factory
+}
+
+// Types returns the names of all registered cipher types
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Register adds an algorithm to the registry, replacing any algorithm with the same ID
+func Register(algo Algorithm) error {
+	return registerAll([]Algorithm{algo})
+}
+
+// registerAll validates every algorithm and registers them all, or none on error
+func registerAll(algos []Algorithm) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	valid := make([]Algorithm, len(algos))
+	for i, algo := range algos {
+		algo.ID = strings.ToUpper(strings.TrimSpace(algo.ID))
+		algo.Type = strings.ToLower(algo.Type)
+		if algo.ID == "" {
+			return fmt.Errorf("algorithm id is empty")
+		}
+		if algo.Keys == nil {
+			return fmt.Errorf("algorithm %s has no key provider", algo.ID)
+		}
+		if _, ok := types[algo.Type]; !ok {
+			return fmt.Errorf("algorithm %s has unknown cipher type: %s", algo.ID, algo.Type)
+		}
+		valid[i] = algo
+	}
+	for _, algo := range valid {
+		algorithms[algo.ID] = algo
+	}
+	return nil
+}
+
+// MustRegister is like Register but panics on error, for use in init functions
+func MustRegister(algo Algorithm) {
+	if err := Register(algo); err != nil {
+		panic(err)
+	}
+}
+
+// Lookup returns the registered algorithm with the given ID
+func Lookup(algoID string) (Algorithm, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	algo, ok := algorithms[strings.ToUpper(algoID)]
+	return algo, ok
+}
+
+// ListAlgorithms returns all registered algorithms sorted by ID
+func ListAlgorithms() []Algorithm {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	list := make([]Algorithm, 0, len(algorithms))
+	for _, algo := range algorithms {
+		list = append(list, algo)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// StaticKeys returns a KeyProvider for fixed key material
+func StaticKeys(iv []byte, keys ...[]byte) KeyProvider {
+	return func() (*KeyMaterial, error) {
+		return &KeyMaterial{Keys: keys, IV: iv}, nil
+	}
+}
+
+// expect ensures the key material carries the number of keys and the IV a type needs
+func (km *KeyMaterial) expect(keys int, iv bool) error {
+	if len(km.Keys) != keys {
+		return fmt.Errorf("expected %d keys, got %d", keys, len(km.Keys))
+	}
+	if iv && len(km.IV) == 0 {
+		return fmt.Errorf("missing iv")
+	}
+	return nil
+}
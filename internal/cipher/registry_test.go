@@ -0,0 +1,120 @@
+package cipher
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// saveRegistry restores the registered algorithms and types after the test
+func saveRegistry(t *testing.T) {
+	t.Helper()
+	registryMu.Lock()
+	savedAlgos := make(map[string]Algorithm, len(algorithms))
+	for id, algo := range algorithms {
+		savedAlgos[id] = algo
+	}
+	savedTypes := make(map[string]Factory, len(types))
+	for name, f := range types {
+		savedTypes[name] = f
+	}
+	registryMu.Unlock()
+
+	t.Cleanup(func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		algorithms = savedAlgos
+		types = savedTypes
+	})
+}
+
+// registeredIDs lists the registered algorithms as ID=Name pairs
+func registeredIDs() string {
+	var ids []string
+	for _, algo := range ListAlgorithms() {
+		ids = append(ids, algo.ID+"="+algo.Name)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestBuiltinAlgorithms(t *testing.T) {
+	algos := ListAlgorithms()
+	if len(algos) == 0 {
+		t.Fatal("no built-in algorithm")
+	}
+	for i, algo := range algos {
+		if i > 0 && algos[i-1].ID >= algo.ID {
+			t.Errorf("ListAlgorithms not sorted: %s before %s", algos[i-1].ID, algo.ID)
+		}
+		c, err := GetInstance(strings.ToLower(algo.ID))
+		if err != nil {
+			t.Errorf("GetInstance(%s %s): %v", algo.ID, algo.Name, err)
+			continue
+		}
+		plain := []byte("0123456789abcdef")
+		enc, err := c.Encrypt(plain)
+		if err != nil {
+			t.Errorf("%s: Encrypt: %v", algo.Name, err)
+			continue
+		}
+		dec, err := c.Decrypt(enc)
+		if err != nil || !bytes.Equal(dec, plain) {
+			t.Errorf("%s: Decrypt = %q, %v", algo.Name, dec, err)
+		}
+	}
+	if _, err := GetInstance("00000000-0000-0000-0000-000000000000"); err == nil {
+		t.Error("GetInstance of an unknown id succeeded")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	saveRegistry(t)
+	keys := StaticKeys(nil, bytes.Repeat([]byte{1}, 16))
+
+	tests := []struct {
+		name string
+		algo Algorithm
+	}{
+		{"empty id", Algorithm{ID: " ", Type: "sm4-ecb", Keys: keys}},
+		{"no keys", Algorithm{ID: "TEST-1", Type: "sm4-ecb"}},
+		{"unknown type", Algorithm{ID: "TEST-1", Type: "rot13", Keys: keys}},
+	}
+	before := registeredIDs()
+	for _, tt := range tests {
+		if err := Register(tt.algo); err == nil {
+			t.Errorf("Register with %s succeeded", tt.name)
+		}
+	}
+	if got := registeredIDs(); got != before {
+		t.Errorf("failed registrations changed the registry:\n%s\nwant:\n%s", got, before)
+	}
+
+	if err := Register(Algorithm{ID: "test-1", Name: "first", Type: "SM4-ECB", Keys: keys}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(Algorithm{ID: "TEST-1", Name: "second", Type: "sm4-ecb", Keys: keys}); err != nil {
+		t.Fatal(err)
+	}
+	algo, ok := Lookup("Test-1")
+	if !ok || algo.Name != "second" || algo.Type != "sm4-ecb" {
+		t.Errorf("Lookup = %+v, %v, want the replacement", algo, ok)
+	}
+}
+
+func TestRegisterType(t *testing.T) {
+	saveRegistry(t)
+	RegisterType("Test-Type", newSM4ECB)
+	found := false
+	for _, name := range Types() {
+		found = found || name == "test-type"
+	}
+	if !found {
+		t.Fatalf("Types() = %v, want test-type", Types())
+	}
+	if err := Register(Algorithm{ID: "TEST-2", Type: "test-type", Keys: StaticKeys(nil, make([]byte, 16))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetInstance("TEST-2"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,122 @@
+package cipher
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	impl "github.com/Rsplwe/ESurfingDialer/internal/cipher/impl"
+)
+
+// types maps cipher type names to their factories, RegisterType adds more
+var types = map[string]Factory{
+	"aes-cbc":    newAESCBC,
+	"aes-ecb":    newAESECB,
+	"desede-cbc": newDESedeCBC,
+	"desede-ecb": newDESedeECB,
+	"zuc":        newZUC,
+	"sm4-cbc":    newSM4CBC,
+	"sm4-ecb":    newSM4ECB,
+	"modxtea":    newModXTEA,
+	"modxtea-iv": newModXTEAIV,
+}
+
+func newAESCBC(km *KeyMaterial) (CipherInterface, error) {
+	if err := km.expect(2, true); err != nil {
+		return nil, err
+	}
+	return impl.NewAESCBC(km.Keys[0], km.Keys[1], km.IV), nil
+}
+
+func newAESECB(km *KeyMaterial) (CipherInterface, error) {
+	if err := km.expect(2, false); err != nil {
+		return nil, err
+	}
+	return impl.NewAESECB(km.Keys[0], km.Keys[1]), nil
+}
+
+func newDESedeCBC(km *KeyMaterial) (CipherInterface, error) {
+	if err := km.expect(2, true); err != nil {
+		return nil, err
+	}
+	return impl.NewDESedeCBC(km.Keys[0], km.Keys[1], km.IV), nil
+}
+
+func newDESedeECB(km *KeyMaterial) (CipherInterface, error) {
+	if err := km.expect(2, false); err != nil {
+		return nil, err
+	}
+	return impl.NewDESedeECB(km.Keys[0], km.Keys[1]), nil
+}
+
+func newZUC(km *KeyMaterial) (CipherInterface, error) {
+	if err := km.expect(1, true); err != nil {
+		return nil, err
+	}
+	return impl.NewZUC(km.Keys[0], km.IV), nil
+}
+
+func newSM4CBC(km *KeyMaterial) (CipherInterface, error) {
+	if err := km.expect(1, true); err != nil {
+		return nil, err
+	}
+	return impl.NewSM4CBC(km.Keys[0], km.IV), nil
+}
+
+func newSM4ECB(km *KeyMaterial) (CipherInterface, error) {
+	if err := km.expect(1, false); err != nil {
+		return nil, err
+	}
+	return impl.NewSM4ECB(km.Keys[0]), nil
+}
+
+func newModXTEA(km *KeyMaterial) (CipherInterface, error) {
+	if err := km.expect(3, false); err != nil {
+		return nil, err
+	}
+	return impl.NewModXTEA(bytesToWords(km.Keys[0]), bytesToWords(km.Keys[1]), bytesToWords(km.Keys[2])), nil
+}
+
+func newModXTEAIV(km *KeyMaterial) (CipherInterface, error) {
+	if err := km.expect(3, true); err != nil {
+		return nil, err
+	}
+	return impl.NewModXTEAIV(bytesToWords(km.Keys[0]), bytesToWords(km.Keys[1]), bytesToWords(km.Keys[2]), bytesToWords(km.IV)), nil
+}
+
+// wordsToBytes encodes XTEA key words as big-endian bytes
+func wordsToBytes(words []uint32) []byte {
+	result := make([]byte, len(words)*4)
+	for i, w := range words {
+		binary.BigEndian.PutUint32(result[i*4:], w)
+	}
+	return result
+}
+
+// bytesToWords decodes big-endian bytes into XTEA key words, ignoring any partial word
+func bytesToWords(data []byte) []uint32 {
+	result := make([]uint32, len(data)/4)
+	for i := range result {
+		result[i] = binary.BigEndian.Uint32(data[i*4:])
+	}
+	return result
+}
+
+// newInstance creates the cipher for a registered algorithm
+func newInstance(algo Algorithm) (CipherInterface, error) {
+	registryMu.RLock()
+	factory, ok := types[algo.Type]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown cipher type: %s", algo.Type)
+	}
+
+	km, err := algo.Keys()
+	if err != nil {
+		return nil, fmt.Errorf("algorithm %s keys: %w", algo.ID, err)
+	}
+	c, err := factory(km)
+	if err != nil {
+		return nil, fmt.Errorf("algorithm %s: %w", algo.ID, err)
+	}
+	return c, nil
+}
@@ -1,18 +1,25 @@
 package client
 
 import (
-	"bufio"
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Rsplwe/ESurfingDialer/internal/backoff"
+	"github.com/Rsplwe/ESurfingDialer/internal/cipher"
 	"github.com/Rsplwe/ESurfingDialer/internal/constants"
+	"github.com/Rsplwe/ESurfingDialer/internal/metrics"
 	"github.com/Rsplwe/ESurfingDialer/internal/models"
 	"github.com/Rsplwe/ESurfingDialer/internal/network"
 	"github.com/Rsplwe/ESurfingDialer/internal/session"
+	"github.com/Rsplwe/ESurfingDialer/internal/smscode"
 	"github.com/Rsplwe/ESurfingDialer/internal/states"
 	"github.com/Rsplwe/ESurfingDialer/internal/utils"
 )
@@ -28,123 +35,487 @@ type LoginResponse struct {
 	KeepURL   string   `xml:"keep-url"`
 	TermURL   string   `xml:"term-url"`
 	KeepRetry string   `xml:"keep-retry"`
+	ResCode   string   `xml:"rescode"`
+	ResInfo   string   `xml:"resinfo"`
 }
 
 type HeartbeatResponse struct {
 	XMLName  xml.Name `xml:"response"`
 	Interval string   `xml:"interval"`
+	ResCode  string   `xml:"rescode"`
+	ResInfo  string   `xml:"resinfo"`
+}
+
+// HeartbeatResult is the parsed keep response of one heartbeat
+type HeartbeatResult struct {
+	Interval time.Duration // Next heartbeat interval, zero keeps the current one
+	ResCode  string
+	ResInfo  string
+}
+
+// HeartbeatError is returned when the portal rejects a heartbeat
+type HeartbeatError struct {
+	ResCode string
+	ResInfo string
+}
+
+func (e *HeartbeatError) Error() string {
+	if e.ResInfo == "" {
+		return fmt.Sprintf("heartbeat rejected with rescode %s", e.ResCode)
+	}
+	return fmt.Sprintf("heartbeat rejected with rescode %s: %s", e.ResCode, e.ResInfo)
 }
 
 // Client handles the authentication and keep-alive logic
+
 type Client struct {
-	options   *models.Options
-	keepURL   string
-	termURL   string
-	keepRetry string
-	tick      int64
-	httpClient *http.Client
+	options     *models.Options
+	state       *states.State
+	mu          sync.Mutex // Guards the login results, errors and SMS requests read by Term and Status
+	keepURL     string
+	termURL     string
+	keepRetry   string
+	tick        int64
+	lastErr     error
+	lastErrTime time.Time
+	verify      network.VerifyResult // Last SMS code request
+	hbFailures  int                  // Consecutive failed heartbeats
+	verifyTime  time.Time
+	codeTime    time.Time // Start of the last SMS request, the cooldown runs from it
+	httpClient  *http.Client
+	session     *session.Session
+	logger      *slog.Logger
+	metrics     *metrics.Dialer
+	backoff     *backoff.Backoff // Delays retries of failed probes and logins
+
+	commands     chan command     // Control requests handled by Run
+	codes        chan string      // Verification codes from SubmitCode
+	paused       atomic.Bool      // Set by RequestLogout, Run stays offline
+	remoteCode   atomic.Bool      // Wait for SubmitCode when the code provider fails
+	codeRejected bool             // The portal refused the last code, the next login requests a new one
+	codeProvider smscode.Provider // Stdin when nil
+	probes       network.ProbeConfig
+	liveness     network.Liveness // Checks made instead of a portal detection while logged in
 }
 
+// Default client loop timing
+const (
+	defaultProbeInterval     = 1 * time.Second
+	defaultHeartbeatFailures = 3
+	livenessTimeout          = 10 * time.Second
+)
+
+var (
+	// ErrRequest is returned when portal detection fails
+	ErrRequest = errors.New("request error")
+	// ErrNotLoggedIn is returned when an operation requires an authorized session
+	ErrNotLoggedIn = errors.New("not logged in")
+	// ErrSessionInit is returned when the portal chose an algorithm that is not implemented
+	ErrSessionInit = errors.New("unable to initialize session")
+	// ErrStillCaptive is recorded when detection still requires authorization right after a login
+	ErrStillCaptive = errors.New("portal still requires authorization after a login")
+	// ErrEmptyKeepURL is returned when the login response has no keep-url
+	ErrEmptyKeepURL = errors.New("KeepUrl is empty")
+)
+
 // New creates a new Client instance
-func New(options *models.Options) *Client {
-	return &Client{
+func New(options *models.Options, st *states.State) *Client {
+	c := &Client{
 		options:    options,
-		httpClient: network.CreateHTTPClient(),
+		state:      st,
+		httpClient: network.CreateHTTPClient(st),
+		session:    session.New(),
+		logger:     st.Logger().With("component", "client"),
+		commands:   make(chan command),
+		codes:      make(chan string),
 	}
+	c.backoff = backoff.New(options.Backoff)
+	c.session.SetLogger(st.Logger())
+	return c
+}
+
+// SetLogger sets the logger client, session and network messages are written to
+func (c *Client) SetLogger(logger *slog.Logger) {
+	c.logger = logger.With("component", "client")
+	c.state.SetLogger(logger)
+	c.session.SetLogger(logger)
+}
+
+// SetMetrics sets the metrics the client records, nil records none
+func (c *Client) SetMetrics(m *metrics.Dialer) {
+	c.metrics = m
+}
+
+// SetProbes sets the connectivity check targets, network.DefaultProbes when none are given
+func (c *Client) SetProbes(cfg network.ProbeConfig) {
+	c.probes = cfg
+}
+
+// SetLiveness sets the cheap checks made while logged in, a failed one falls back to a portal detection
+func (c *Client) SetLiveness(l network.Liveness) {
+	c.liveness = l
+}
+
+// SetTransport sets the HTTP transport used for every portal, probe and
+// verification request, see network.BindTransport for the interface binding
+func (c *Client) SetTransport(rt http.RoundTripper) {
+	c.httpClient.Transport = network.BindTransport(rt, c.state)
 }
 
-// Run starts the main client loop
-func (c *Client) Run() {
-	for states.IsRunning {
-		networkStatus := network.DetectConfig()
+// Run starts the main client loop, it returns when ctx is cancelled or the client stops
+func (c *Client) Run(ctx context.Context) {
+	authorized := false // The last iteration logged in
+	for c.state.IsRunning() && ctx.Err() == nil {
+		if c.paused.Load() {
+			authorized = false
+			c.wait(ctx, 0)
+			continue
+		}
+
+		networkStatus := c.check(ctx)
+		if networkStatus != network.RequireAuthorization {
+			authorized = false
+		}
 		
 		switch networkStatus {
 		case network.Success:
-			if session.IsInitialized() && states.IsLogged {
-				if (time.Now().UnixMilli() - c.tick) >= (parseRetry(c.keepRetry) * 1000) {
-					fmt.Println("Send Keep Packet")
-					c.heartbeat(states.Ticket)
-					fmt.Printf("Next Retry: %s\n", c.keepRetry)
-					c.tick = time.Now().UnixMilli()
+			if c.session.IsInitialized() && c.state.IsLogged() {
+				if (time.Now().UnixMilli() - c.lastTick()) >= int64(c.KeepRetry()/time.Millisecond) {
+					c.logger.Debug("Sending heartbeat")
+					if _, err := c.Keepalive(ctx); err != nil && ctx.Err() == nil {
+						failures := c.HeartbeatFailures()
+						c.logger.Error("Heartbeat failed", "error", err, "failures", failures)
+						c.setError(err)
+						if failures >= c.heartbeatThreshold() {
+							if err := c.relogin(ctx); err != nil {
+								c.loginFailed(ctx, "Login after failed heartbeats failed", err)
+								continue
+							}
+						}
+					}
+					c.logger.Debug("Next heartbeat scheduled", "interval", c.KeepRetry())
 				}
 			} else {
-				fmt.Println("The network has been connected.")
+				c.logger.Debug("The network has been connected")
 			}
-			time.Sleep(1 * time.Second)
+			c.backoff.Reset()
+			c.wait(ctx, c.checkInterval())
 			
 		case network.RequireAuthorization:
-			states.IsLogged = false
-			c.authorization()
+			if authorized {
+				// The portal accepted the login but still redirects, the
+				// backoff is only reset once a probe succeeds
+				authorized = false
+				c.retryAfter(ctx, "Portal still requires authorization after a login", ErrStillCaptive)
+				continue
+			}
+			c.setLoggedOut()
+			if err := c.authorization(ctx); err != nil {
+				c.loginFailed(ctx, "Authorization failed", err)
+			} else {
+				authorized = true
+			}
 			
 		case network.RequestError:
-			fmt.Println("Request Error")
-			time.Sleep(5 * time.Second)
+			if ctx.Err() != nil {
+				return
+			}
+			c.retryAfter(ctx, "Connectivity check failed", ErrRequest)
+		}
+	}
+}
+
+func (c *Client) probeInterval() time.Duration {
+	if c.options.ProbeInterval > 0 {
+		return c.options.ProbeInterval
+	}
+	return defaultProbeInterval
+}
+
+// loginFailed decides by the failure reason whether to stop, request a new SMS code or retry later
+func (c *Client) loginFailed(ctx context.Context, msg string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	var loginErr *LoginError
+	if !errors.As(err, &loginErr) {
+		if errors.Is(err, ErrSessionInit) {
+			c.logger.Error(msg, "error", err)
+			c.setError(err)
+			c.state.SetRunning(false)
+			return
+		}
+		c.retryAfter(ctx, msg, err)
+		return
+	}
+
+	reason := loginErr.Reason
+	switch {
+	case reason.Fatal():
+		c.logger.Error(msg+", stopping", "error", err, "reason", reason, "hint", reason.Hint())
+		c.setError(err)
+		c.state.SetRunning(false)
+	case reason == LoginBadCode:
+		// No delay, requesting the new code waits for the cooldown
+		c.logger.Warn(msg, "error", err, "reason", reason, "hint", reason.Hint())
+		c.setError(err)
+		c.options.SmsCode = ""
+		c.codeRejected = true
+	default:
+		c.retryAfter(ctx, msg, err, "reason", reason, "hint", reason.Hint())
+	}
+}
+
+// retryAfter logs a failed attempt with extra attributes and waits for the next backoff delay
+func (c *Client) retryAfter(ctx context.Context, msg string, err error, args ...any) {
+	delay := c.backoff.Next()
+	args = append([]any{"error", err, "delay", delay.Round(time.Millisecond), "failures", c.backoff.Failures()}, args...)
+	c.logger.Warn(msg+", retrying", args...)
+	c.setError(err)
+	c.wait(ctx, delay)
+}
+
+// detect runs a connectivity check and records its outcome
+func (c *Client) detect(ctx context.Context) network.ConnectivityStatus {
+	status := network.DetectConfig(ctx, c.state, c.httpClient, c.probes)
+	c.metrics.Connectivity(status.String())
+	return status
+}
+
+// check runs a liveness check while logged in and a portal detection when it fails or is disabled
+func (c *Client) check(ctx context.Context) network.ConnectivityStatus {
+	if !c.liveness.Enabled() || !c.session.IsInitialized() || !c.state.IsLogged() {
+		return c.detect(ctx)
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, livenessTimeout)
+	defer cancel()
+	err := c.liveness.Check(checkCtx, c.state, c.httpClient, c.probes)
+	if err == nil {
+		c.metrics.Connectivity("alive")
+		return network.Success
+	}
+	if ctx.Err() != nil {
+		return network.RequestError
+	}
+	c.metrics.Connectivity("not_alive")
+	c.logger.Info("Liveness check failed, detecting the portal", "mode", c.liveness.Mode, "error", err)
+	return c.detect(ctx)
+}
+
+// checkInterval returns the delay until the next check while online, liveness
+// checks keep their own interval but never delay a due heartbeat
+func (c *Client) checkInterval() time.Duration {
+	interval := c.probeInterval()
+	if !c.liveness.Enabled() || c.liveness.Interval <= 0 || !c.state.IsLogged() {
+		return interval
+	}
+	interval = c.liveness.Interval
+	next := time.UnixMilli(c.lastTick()).Add(c.KeepRetry())
+	if untilHeartbeat := time.Until(next); untilHeartbeat < interval {
+		interval = max(untilHeartbeat, c.probeInterval())
+	}
+	return interval
+}
+
+// setLoggedOut marks the session as no longer authorized
+func (c *Client) setLoggedOut() {
+	c.state.SetLogged(false)
+	c.metrics.LoggedOut()
+	c.dropConnections()
+}
+
+// dropConnections closes the idle connections kept by the shared client, a
+// connection opened on one side of a login may be intercepted or reset on the other
+func (c *Client) dropConnections() {
+	c.httpClient.CloseIdleConnections()
+}
+
+// post sends data to url and records the request latency under endpoint
+func (c *Client) post(ctx context.Context, endpoint, url, data string) *network.NetResult {
+	start := time.Now()
+	result := network.Post(ctx, c.httpClient, c.state, url, data, nil)
+	c.metrics.Request(endpoint, start)
+	if result.Error != nil {
+		result.Error = fmt.Errorf("%s: %w", endpoint, result.Error)
+	}
+	return result
+}
+
+// lastTick returns the time of the last login or heartbeat in milliseconds
+func (c *Client) lastTick() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tick
+}
+
+// setTick records the time of a login or heartbeat
+func (c *Client) setTick() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tick = time.Now().UnixMilli()
+}
+
+// Login detects the portal and authorizes if it requires so,
+// it returns nil without logging in when the network is already connected
+func (c *Client) Login(ctx context.Context) error {
+	switch c.detect(ctx) {
+	case network.Success:
+		return nil
+	case network.RequireAuthorization:
+		c.setLoggedOut()
+		return c.authorization(ctx)
+	default:
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		return ErrRequest
+	}
+}
+
+// Keepalive sends a heartbeat and returns the interval until the next one
+func (c *Client) Keepalive(ctx context.Context) (time.Duration, error) {
+	if !c.session.IsInitialized() || !c.state.IsLogged() {
+		return 0, ErrNotLoggedIn
+	}
+	_, err := c.heartbeat(ctx, c.state.Ticket())
+	c.setTick()
+	c.mu.Lock()
+	if err != nil {
+		c.hbFailures++
+	} else {
+		c.hbFailures = 0
+	}
+	c.mu.Unlock()
+	c.metrics.HeartbeatDone(err)
+	c.metrics.KeepRetry(c.KeepRetry())
+	return c.KeepRetry(), err
+}
+
+// HeartbeatFailures returns the number of consecutive failed heartbeats
+func (c *Client) HeartbeatFailures() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hbFailures
+}
+
+func (c *Client) heartbeatThreshold() int {
+	if c.options.HeartbeatFailures > 0 {
+		return c.options.HeartbeatFailures
+	}
+	return defaultHeartbeatFailures
+}
+
+// relogin drops a session whose heartbeats keep failing and authorizes again
+func (c *Client) relogin(ctx context.Context) error {
+	c.logger.Warn("Too many failed heartbeats, logging in again", "threshold", c.heartbeatThreshold())
+	if err := c.Term(ctx); err != nil {
+		c.logger.Debug("Terminating the failed session", "error", err)
+	}
+	c.setLoggedOut()
+	c.Close()
+
+	return c.authorization(ctx)
+}
+
+// KeepRetry returns the heartbeat interval requested by the portal
+func (c *Client) KeepRetry() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Duration(parseRetry(c.keepRetry)) * time.Second
+}
+
+// Logout terminates the session and frees it
+func (c *Client) Logout(ctx context.Context) error {
+	if !c.state.IsLogged() {
+		return ErrNotLoggedIn
 	}
+	err := c.Term(ctx)
+	c.setLoggedOut()
+	c.Close()
+	return err
 }
 
-func (c *Client) authorization() {
+func (c *Client) authorization(ctx context.Context) error {
+	c.metrics.LoginAttempted()
+	err := c.authorize(ctx)
+	c.metrics.LoginDone(err)
+	if err == nil {
+		c.metrics.KeepRetry(c.KeepRetry())
+	}
+	return err
+}
+
+func (c *Client) authorize(ctx context.Context) error {
 	code := c.options.SmsCode
 	if code == "" {
-		code = c.checkSMSVerify()
+		var err error
+		code, err = c.checkSMSVerify(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	if code != "" {
+		c.logger.Info("Using SMS verification code")
 	}
-	fmt.Printf("SMS Code is: %s\n", code)
-	
-	states.RefreshStates()
-	c.initSession()
 	
-	if !session.IsInitialized() {
-		fmt.Println("Unable to find algorithm implementation, please restart the application or try version 1.8.0 or below.")
-		fmt.Println("Release: https://github.com/Rsplwe/ESurfingDialer/releases")
-		states.IsRunning = false
-		return
+	c.state.Refresh()
+	if err := c.initSession(ctx); err != nil {
+		if errors.Is(err, ErrSessionInit) {
+			c.logger.Error("Unable to find algorithm implementation, please restart the application or try version 1.8.0 or below",
+				"release", "https://github.com/Rsplwe/ESurfingDialer/releases")
+		}
+		return err
 	}
 	
-	fmt.Printf("Client IP: %s\n", states.UserIP)
-	fmt.Printf("AC IP: %s\n", states.ACIP)
+	c.logger.Info("Portal detected", "user_ip", c.state.UserIP(), "ac_ip", c.state.ACIP())
 	
-	states.Ticket = c.getTicket()
-	fmt.Printf("Ticket: %s\n", states.Ticket)
+	ticket, err := c.getTicket(ctx)
+	if err != nil {
+		c.session.Close()
+		return err
+	}
+	c.state.SetTicket(ticket)
+	c.logger.Debug("Ticket obtained")
 	
-	c.login(code)
+	if err := c.login(ctx, code); err != nil {
+		c.session.Close()
+		return err
+	}
 	if c.keepURL == "" {
-		fmt.Println("KeepUrl is empty.")
-		session.Free()
-		states.IsRunning = false
-		return
+		c.session.Close()
+		return ErrEmptyKeepURL
 	}
 	
-	c.tick = time.Now().UnixMilli()
-	states.IsLogged = true
-	fmt.Println("The login has been authorized.")
-}
-
-func (c *Client) checkSMSVerify() string {
-	if network.CheckVerifyCodeStatus(c.options.LoginUser) && network.GetVerifyCode(c.options.LoginUser) {
-		fmt.Println("This login requires a SMS verification code.")
-		reader := bufio.NewReader(os.Stdin)
-		for {
-			fmt.Print("Input Code: ")
-			input, _ := reader.ReadString('\n')
-			code := strings.TrimSpace(input)
-			if code != "" {
-				return code
-			}
-		}
-	}
-	return ""
+	c.setTick()
+	c.mu.Lock()
+	c.hbFailures = 0
+	c.mu.Unlock()
+	c.state.SetLogged(true)
+	c.dropConnections()
+	c.logger.Info("The login has been authorized")
+	return nil
 }
 
-func (c *Client) initSession() {
-	result := network.Post(c.httpClient, states.TicketURL, states.AlgoID, nil)
+// initSession negotiates the cipher session, it fails with ErrSessionInit
+// when the portal picked an algorithm that is not implemented
+func (c *Client) initSession(ctx context.Context) error {
+	result := c.post(ctx, metrics.EndpointTicket, c.state.TicketURL(), c.state.AlgoID())
 	if result.Error != nil {
-		fmt.Printf("Error: %v\n", result.Error)
-		return
+		return fmt.Errorf("session request: %w", result.Error)
 	}
-	session.Initialize(result.Data)
+	if err := c.session.Init(result.Data); err != nil {
+		if errors.Is(err, cipher.ErrUnknownAlgorithm) {
+			return fmt.Errorf("%w: %v", ErrSessionInit, err)
+		}
+		return fmt.Errorf("session init: %w", err)
+	}
+	c.state.SetAlgoID(c.session.AlgoID())
+	return nil
 }
 
-func (c *Client) getTicket() string {
+func (c *Client) getTicket(ctx context.Context) (string, error) {
 	payload := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
 <request>
     <user-agent>%s</user-agent>
@@ -158,35 +529,41 @@ func (c *Client) getTicket() string {
     <gwip>%s</gwip>
 </request>`,
 		constants.UserAgent,
-		states.ClientID,
+		c.state.ClientID(),
 		utils.GetTime(),
 		constants.HostName,
-		states.UserIP,
-		states.MacAddress,
+		c.state.UserIP(),
+		c.state.MacAddress(),
 		constants.HostName,
-		states.ACIP,
+		c.state.ACIP(),
 	)
 	
-	result := network.Post(c.httpClient, states.TicketURL, session.Encrypt(payload), nil)
+	encrypted, err := c.session.Encrypt(payload)
+	if err != nil {
+		return "", err
+	}
+	
+	result := c.post(ctx, metrics.EndpointTicket, c.state.TicketURL(), encrypted)
 	if result.Error != nil {
-		fmt.Printf("Error: %v\n", result.Error)
-		return ""
+		return "", result.Error
 	}
 	
-	data := session.Decrypt(string(result.Data))
+	data, err := c.session.Decrypt(string(result.Data))
+	if err != nil {
+		return "", fmt.Errorf("ticket response: %w", err)
+	}
 	
 	var resp TicketResponse
-	err := utils.UnmarshalXML([]byte(data), &resp)
+	err = utils.UnmarshalXML([]byte(data), &resp)
 	if err != nil {
-		fmt.Printf("Error parsing ticket XML: %v\n", err)
-		fmt.Printf("XML data: %s\n", data)
-		return ""
+		c.logger.Debug("Unparsable response", "length", len(data))
+		return "", fmt.Errorf("parsing ticket XML: %w", err)
 	}
 	
-	return strings.TrimSpace(resp.Ticket)
+	return strings.TrimSpace(resp.Ticket), nil
 }
 
-func (c *Client) login(code string) {
+func (c *Client) login(ctx context.Context, code string) error {
 	verify := ""
 	if code != "" {
 		verify = fmt.Sprintf("<verify>%s</verify>", code)
@@ -203,40 +580,53 @@ func (c *Client) login(code string) {
     %s
 </request>`,
 		constants.UserAgent,
-		states.ClientID,
-		states.Ticket,
+		c.state.ClientID(),
+		c.state.Ticket(),
 		utils.GetTime(),
 		c.options.LoginUser,
 		c.options.LoginPassword,
 		verify,
 	)
 	
-	result := network.Post(c.httpClient, states.AuthURL, session.Encrypt(payload), nil)
+	encrypted, err := c.session.Encrypt(payload)
+	if err != nil {
+		return err
+	}
+	
+	result := c.post(ctx, metrics.EndpointAuth, c.state.AuthURL(), encrypted)
 	if result.Error != nil {
-		fmt.Printf("Error: %v\n", result.Error)
-		return
+		return result.Error
 	}
 	
-	data := session.Decrypt(string(result.Data))
+	data, err := c.session.Decrypt(string(result.Data))
+	if err != nil {
+		return fmt.Errorf("login response: %w", err)
+	}
 	
 	var resp LoginResponse
-	err := utils.UnmarshalXML([]byte(data), &resp)
+	err = utils.UnmarshalXML([]byte(data), &resp)
 	if err != nil {
-		fmt.Printf("Error parsing login XML: %v\n", err)
-		fmt.Printf("XML data: %s\n", data)
-		return
+		c.logger.Debug("Unparsable response", "length", len(data))
+		return fmt.Errorf("parsing login XML: %w", err)
 	}
 	
+	resCode := strings.TrimSpace(resp.ResCode)
+	resInfo := strings.TrimSpace(resp.ResInfo)
+	if (resCode != "" && resCode != "0") || (resp.KeepURL == "" && resInfo != "") {
+		return newLoginError(resCode, resInfo)
+	}
+	
+	c.mu.Lock()
 	c.keepURL = strings.TrimSpace(resp.KeepURL)
 	c.termURL = strings.TrimSpace(resp.TermURL)
 	c.keepRetry = strings.TrimSpace(resp.KeepRetry)
+	c.mu.Unlock()
 	
-	fmt.Printf("Keep Url: %s\n", c.keepURL)
-	fmt.Printf("Term Url: %s\n", c.termURL)
-	fmt.Printf("Keep Retry: %s\n", c.keepRetry)
+	c.logger.Info("Login accepted", "keep_retry", c.keepRetry)
+	return nil
 }
 
-func (c *Client) heartbeat(ticket string) {
+func (c *Client) heartbeat(ctx context.Context, ticket string) (HeartbeatResult, error) {
 	payload := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
 <request>
     <user-agent>%s</user-agent>
@@ -250,38 +640,61 @@ func (c *Client) heartbeat(ticket string) {
     <ostag>%s</ostag>
 </request>`,
 		constants.UserAgent,
-		states.ClientID,
+		c.state.ClientID(),
 		utils.GetTime(),
 		constants.HostName,
-		states.UserIP,
+		c.state.UserIP(),
 		ticket,
-		states.MacAddress,
+		c.state.MacAddress(),
 		constants.HostName,
 	)
 	
-	result := network.Post(c.httpClient, c.keepURL, session.Encrypt(payload), nil)
+	encrypted, err := c.session.Encrypt(payload)
+	if err != nil {
+		return HeartbeatResult{}, err
+	}
+	
+	result := c.post(ctx, metrics.EndpointKeep, c.keepURL, encrypted)
 	if result.Error != nil {
-		fmt.Printf("Error: %v\n", result.Error)
-		return
+		return HeartbeatResult{}, result.Error
 	}
 	
-	data := session.Decrypt(string(result.Data))
+	data, err := c.session.Decrypt(string(result.Data))
+	if err != nil {
+		return HeartbeatResult{}, fmt.Errorf("heartbeat response: %w", err)
+	}
 	
 	var resp HeartbeatResponse
-	err := utils.UnmarshalXML([]byte(data), &resp)
+	err = utils.UnmarshalXML([]byte(data), &resp)
 	if err != nil {
-		fmt.Printf("Error parsing heartbeat XML: %v\n", err)
-		fmt.Printf("XML data: %s\n", data)
-		return
+		c.logger.Debug("Unparsable response", "length", len(data))
+		return HeartbeatResult{}, fmt.Errorf("parsing heartbeat XML: %w", err)
+	}
+	
+	res := HeartbeatResult{
+		Interval: time.Duration(parseRetry(resp.Interval)) * time.Second,
+		ResCode:  strings.TrimSpace(resp.ResCode),
+		ResInfo:  strings.TrimSpace(resp.ResInfo),
+	}
+	if res.ResCode != "" && res.ResCode != "0" {
+		return res, &HeartbeatError{ResCode: res.ResCode, ResInfo: res.ResInfo}
 	}
 	
 	if resp.Interval != "" {
+		c.mu.Lock()
 		c.keepRetry = strings.TrimSpace(resp.Interval)
+		c.mu.Unlock()
 	}
+	return res, nil
+}
+
+// Close frees the client session
+func (c *Client) Close() {
+	c.session.Close()
 }
 
 // Term terminates the session
-func (c *Client) Term() {
+func (c *Client) Term(ctx context.Context) error {
 	payload := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
 <request>
     <user-agent>%s</user-agent>
@@ -295,16 +708,25 @@ func (c *Client) Term() {
     <ostag>%s</ostag>
 </request>`,
 		constants.UserAgent,
-		states.ClientID,
+		c.state.ClientID(),
 		utils.GetTime(),
 		constants.HostName,
-		states.UserIP,
-		states.Ticket,
-		states.MacAddress,
+		c.state.UserIP(),
+		c.state.Ticket(),
+		c.state.MacAddress(),
 		constants.HostName,
 	)
 	
-	network.Post(c.httpClient, c.termURL, session.Encrypt(payload), nil)
+	encrypted, err := c.session.Encrypt(payload)
+	if err != nil {
+		return err
+	}
+	
+	c.mu.Lock()
+	termURL := c.termURL
+	c.mu.Unlock()
+	
+	return c.post(ctx, metrics.EndpointTerm, termURL, encrypted).Error
 }
 
 func parseRetry(retry string) int64 {
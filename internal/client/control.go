@@ -0,0 +1,233 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/network"
+	"github.com/Rsplwe/ESurfingDialer/internal/smscode"
+)
+
+// ErrStopped is returned by control requests once the client loop has stopped
+var ErrStopped = errors.New("client stopped")
+
+// Status is a snapshot of the client state
+type Status struct {
+	User          string     `json:"user"`
+	Interface     string     `json:"interface,omitempty"`
+	LoggedIn      bool       `json:"logged_in"`
+	Paused        bool       `json:"paused"`
+	UserIP        string     `json:"user_ip"`
+	ACIP          string     `json:"ac_ip"`
+	AlgoID        string     `json:"algo_id"`
+	KeepURL       string     `json:"keep_url"`
+	NextHeartbeat *time.Time `json:"next_heartbeat,omitempty"`
+	HeartbeatFail int        `json:"heartbeat_failures"`
+	RetryFail     int        `json:"retry_failures"` // Consecutive failed probes and logins
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+
+	Verify     *network.VerifyResult `json:"verify,omitempty"` // Last SMS code request
+	VerifyTime *time.Time            `json:"verify_time,omitempty"`
+}
+
+// Status returns the current client state
+func (c *Client) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := Status{
+		User:          c.options.LoginUser,
+		Interface:     c.state.Interface(),
+		LoggedIn:      c.state.IsLogged(),
+		Paused:        c.paused.Load(),
+		UserIP:        c.state.UserIP(),
+		ACIP:          c.state.ACIP(),
+		AlgoID:        c.state.AlgoID(),
+		KeepURL:       c.keepURL,
+		HeartbeatFail: c.hbFailures,
+		RetryFail:     c.backoff.Failures(),
+	}
+	if s.LoggedIn {
+		next := time.UnixMilli(c.tick).Add(time.Duration(parseRetry(c.keepRetry)) * time.Second)
+		s.NextHeartbeat = &next
+	}
+	if c.lastErr != nil {
+		at := c.lastErrTime
+		s.LastError = c.lastErr.Error()
+		s.LastErrorTime = &at
+	}
+	if !c.verifyTime.IsZero() {
+		verify, at := c.verify, c.verifyTime
+		s.Verify = &verify
+		s.VerifyTime = &at
+	}
+	return s
+}
+
+// setError records err as the last error reported by Status
+func (c *Client) setError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastErr = err
+	c.lastErrTime = time.Now()
+}
+
+// Control operations handled by the Run loop
+type operation int
+
+const (
+	opLogin operation = iota
+	opLogout
+	opReconnect
+)
+
+type command struct {
+	op   operation
+	done chan error
+}
+
+// RequestLogin asks the Run loop to log in now and resumes it after RequestLogout
+func (c *Client) RequestLogin(ctx context.Context) error {
+	return c.request(ctx, opLogin)
+}
+
+// RequestLogout asks the Run loop to log out and stay offline until RequestLogin
+func (c *Client) RequestLogout(ctx context.Context) error {
+	return c.request(ctx, opLogout)
+}
+
+// RequestReconnect asks the Run loop to log out and log in again
+func (c *Client) RequestReconnect(ctx context.Context) error {
+	return c.request(ctx, opReconnect)
+}
+
+// request hands op to the Run loop and waits for its result
+func (c *Client) request(ctx context.Context, op operation) error {
+	if !c.state.IsRunning() {
+		return ErrStopped
+	}
+	cmd := command{op: op, done: make(chan error, 1)}
+	select {
+	case c.commands <- cmd:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-cmd.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SubmitCode answers a pending SMS verification prompt, it fails when no code is awaited
+func (c *Client) SubmitCode(code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return errors.New("empty verification code")
+	}
+	select {
+	case c.codes <- code:
+		return nil
+	default:
+		return errors.New("no verification code is awaited")
+	}
+}
+
+// SetRemoteCode sets whether SMS prompts keep waiting for SubmitCode once the code provider fails
+func (c *Client) SetRemoteCode(v bool) {
+	c.remoteCode.Store(v)
+}
+
+// wait pauses for d, forever if d is zero, until ctx is cancelled or a command is handled
+func (c *Client) wait(ctx context.Context, d time.Duration) {
+	var timeout <-chan time.Time
+	if d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ctx.Done():
+	case <-timeout:
+	case cmd := <-c.commands:
+		cmd.done <- c.handle(ctx, cmd.op)
+	}
+}
+
+// handle runs a control operation on the Run loop
+func (c *Client) handle(ctx context.Context, op operation) error {
+	err := c.do(ctx, op)
+	if err != nil && !errors.Is(err, ErrNotLoggedIn) {
+		c.setError(err)
+	}
+	return err
+}
+
+func (c *Client) do(ctx context.Context, op operation) error {
+	switch op {
+	case opLogin:
+		c.paused.Store(false)
+		return c.Login(ctx)
+	case opLogout:
+		c.paused.Store(true)
+		return c.Logout(ctx)
+	case opReconnect:
+		c.paused.Store(false)
+		if c.state.IsLogged() {
+			if err := c.Logout(ctx); err != nil {
+				c.logger.Warn("Logout failed", "error", err)
+			}
+		}
+		return c.Login(ctx)
+	default:
+		return fmt.Errorf("unknown operation %d", op)
+	}
+}
+
+// SetCodeProvider sets where SMS verification codes come from, stdin by default
+func (c *Client) SetCodeProvider(p smscode.Provider) {
+	c.codeProvider = p
+}
+
+// readCode waits for a verification code from the code provider or SubmitCode until ctx is cancelled
+func (c *Client) readCode(ctx context.Context) (string, error) {
+	p := c.codeProvider
+	if p == nil {
+		p = smscode.Stdin{}
+	}
+
+	type result struct {
+		code string
+		err  error
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan result, 1)
+	go func() {
+		code, err := p.Code(ctx, c.options.LoginUser)
+		results <- result{code, err}
+	}()
+
+	for {
+		select {
+		case r := <-results:
+			if r.err == nil {
+				return r.code, nil
+			}
+			if !c.remoteCode.Load() || ctx.Err() != nil {
+				return "", r.err
+			}
+			c.logger.Warn("SMS code provider failed, waiting for the code from the control API", "error", r.err)
+			results = nil
+		case code := <-c.codes:
+			return code, nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Login failure reasons, a *LoginError matches the one of its Reason with errors.Is
+var (
+	// ErrLoginRejected is a login refused for a reason that was not recognized
+	ErrLoginRejected = errors.New("login rejected")
+	// ErrWrongPassword is a login refused for a wrong user or password
+	ErrWrongPassword = errors.New("wrong user or password")
+	// ErrAccountSuspended is a login refused because the account is suspended or locked
+	ErrAccountSuspended = errors.New("account suspended")
+	// ErrArrears is a login refused because the account is out of credit
+	ErrArrears = errors.New("account in arrears")
+	// ErrLoginLimit is a login refused because the account is online on too many devices
+	ErrLoginLimit = errors.New("concurrent login limit reached")
+	// ErrBadCode is a login refused because the SMS verification code was wrong or expired
+	ErrBadCode = errors.New("wrong SMS verification code")
+)
+
+// LoginReason classifies why the portal refused a login
+type LoginReason int
+
+const (
+	LoginRejected LoginReason = iota
+	LoginWrongPassword
+	LoginSuspended
+	LoginArrears
+	LoginLimit
+	LoginBadCode
+)
+
+func (r LoginReason) String() string {
+	switch r {
+	case LoginWrongPassword:
+		return "wrong_password"
+	case LoginSuspended:
+		return "suspended"
+	case LoginArrears:
+		return "arrears"
+	case LoginLimit:
+		return "login_limit"
+	case LoginBadCode:
+		return "bad_code"
+	default:
+		return "rejected"
+	}
+}
+
+func (r LoginReason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// err returns the sentinel error of the reason
+func (r LoginReason) err() error {
+	switch r {
+	case LoginWrongPassword:
+		return ErrWrongPassword
+	case LoginSuspended:
+		return ErrAccountSuspended
+	case LoginArrears:
+		return ErrArrears
+	case LoginLimit:
+		return ErrLoginLimit
+	case LoginBadCode:
+		return ErrBadCode
+	default:
+		return ErrLoginRejected
+	}
+}
+
+// Hint tells the user what to do about a refused login
+func (r LoginReason) Hint() string {
+	switch r {
+	case LoginWrongPassword:
+		return "check the user and password, retrying could lock the account"
+	case LoginSuspended:
+		return "the account is suspended or locked, contact the carrier"
+	case LoginArrears:
+		return "top up the account and start the dialer again"
+	case LoginLimit:
+		return "log out the other devices using this account, retrying meanwhile"
+	case LoginBadCode:
+		return "requesting a new SMS verification code"
+	default:
+		return "retrying"
+	}
+}
+
+// Fatal reports whether retrying the login cannot succeed without the user stepping in
+func (r LoginReason) Fatal() bool {
+	switch r {
+	case LoginWrongPassword, LoginSuspended, LoginArrears:
+		return true
+	default:
+		return false
+	}
+}
+
+// loginCodes are the login rescodes whose meaning is known, they are checked
+// before the message. 11062000 is the verification sub-protocol's "a code is
+// required", which the portal also answers to a login without a valid code.
+var loginCodes = map[string]LoginReason{
+	"11062000": LoginBadCode,
+}
+
+// loginHints match the resinfo of a refused login with an unknown rescode to its
+// reason, in order. Each hint names the cause on its own, generic words such as
+// "limit" or "online" alone could turn a fatal refusal into an endless retry.
+var loginHints = []struct {
+	reason LoginReason
+	hints  []string
+}{
+	{LoginBadCode, []string{"验证码", "verify code", "verification code"}},
+	{LoginArrears, []string{"欠费", "余额不足", "arrears", "insufficient balance"}},
+	{LoginSuspended, []string{"停机", "冻结", "锁定", "禁用", "注销", "suspended", "locked", "disabled", "frozen"}},
+	{LoginLimit, []string{"在线数", "已在线", "登录数", "终端数", "online limit", "already online", "concurrent"}},
+	{LoginWrongPassword, []string{"密码错误", "密码不正确", "用户名或密码", "账号不存在", "用户不存在", "wrong password", "incorrect password", "no such user"}},
+}
+
+// LoginError is a login refused by the portal
+type LoginError struct {
+	Reason  LoginReason
+	ResCode string
+	ResInfo string
+}
+
+func newLoginError(resCode, resInfo string) *LoginError {
+	e := &LoginError{Reason: LoginRejected, ResCode: resCode, ResInfo: resInfo}
+	if reason, ok := loginCodes[resCode]; ok {
+		e.Reason = reason
+		return e
+	}
+	info := strings.ToLower(resInfo)
+	for _, h := range loginHints {
+		for _, hint := range h.hints {
+			if strings.Contains(info, hint) {
+				e.Reason = h.reason
+				return e
+			}
+		}
+	}
+	return e
+}
+
+func (e *LoginError) Error() string {
+	msg := fmt.Sprintf("%s (rescode %s)", e.Reason.err(), e.ResCode)
+	if e.ResInfo != "" {
+		msg += ": " + e.ResInfo
+	}
+	return msg
+}
+
+func (e *LoginError) Unwrap() error {
+	return e.Reason.err()
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/backoff"
+	"github.com/Rsplwe/ESurfingDialer/internal/models"
+	"github.com/Rsplwe/ESurfingDialer/internal/states"
+)
+
+func TestNewLoginError(t *testing.T) {
+	tests := []struct {
+		name    string
+		resCode string
+		resInfo string
+		want    LoginReason
+	}{
+		{"known code", "11062000", "", LoginBadCode},
+		{"known code wins over message", "11062000", "密码错误", LoginBadCode},
+		{"unknown code without hint", "1", "system busy", LoginRejected},
+		{"empty message", "1", "", LoginRejected},
+		{"wrong password", "1", "用户名或密码错误", LoginWrongPassword},
+		{"wrong password english", "1", "Wrong Password", LoginWrongPassword},
+		{"no such user", "1", "用户不存在", LoginWrongPassword},
+		{"suspended", "1", "账号已停机", LoginSuspended},
+		{"locked", "1", "account locked", LoginSuspended},
+		{"arrears", "1", "账户欠费", LoginArrears},
+		{"insufficient balance", "1", "Insufficient balance", LoginArrears},
+		{"online limit", "1", "超过最大在线数", LoginLimit},
+		{"already online", "1", "该账号已在线", LoginLimit},
+		{"bad code", "1", "验证码错误", LoginBadCode},
+		{"bare limit", "1", "rate limit", LoginRejected},
+		{"bare online", "1", "online service unavailable", LoginRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newLoginError(tt.resCode, tt.resInfo)
+			if e.Reason != tt.want {
+				t.Fatalf("reason = %s, want %s", e.Reason, tt.want)
+			}
+			if !errors.Is(e, tt.want.err()) {
+				t.Errorf("errors.Is(%v, %v) = false", e, tt.want.err())
+			}
+			if e.ResCode != tt.resCode || e.ResInfo != tt.resInfo {
+				t.Errorf("got rescode %q resinfo %q", e.ResCode, e.ResInfo)
+			}
+		})
+	}
+}
+
+func newTestClient() *Client {
+	options := &models.Options{
+		SmsCode: "123456",
+		Backoff: backoff.Policy{Initial: time.Millisecond, Max: time.Millisecond, Jitter: -1},
+	}
+	st := states.New()
+	st.SetRunning(true)
+	return New(options, st)
+}
+
+func TestLoginFailed(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		running  bool
+		rejected bool
+		failures int
+	}{
+		{"wrong password", newLoginError("1", "密码错误"), false, false, 0},
+		{"suspended", newLoginError("1", "停机"), false, false, 0},
+		{"arrears", newLoginError("1", "欠费"), false, false, 0},
+		{"bad code", newLoginError("11062000", ""), true, true, 0},
+		{"login limit", newLoginError("1", "已在线"), true, false, 1},
+		{"rejected", newLoginError("1", "system busy"), true, false, 1},
+		{"wrapped", fmt.Errorf("login: %w", newLoginError("1", "欠费")), false, false, 0},
+		{"session init", fmt.Errorf("%w: algorithm", ErrSessionInit), false, false, 0},
+		{"network", errors.New("connection refused"), true, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient()
+			c.loginFailed(context.Background(), "Login failed", tt.err)
+
+			if got := c.state.IsRunning(); got != tt.running {
+				t.Errorf("running = %v, want %v", got, tt.running)
+			}
+			if c.codeRejected != tt.rejected {
+				t.Errorf("codeRejected = %v, want %v", c.codeRejected, tt.rejected)
+			}
+			if tt.rejected && c.options.SmsCode != "" {
+				t.Errorf("SmsCode = %q, want it cleared", c.options.SmsCode)
+			}
+			if !tt.rejected && c.options.SmsCode == "" {
+				t.Error("SmsCode cleared")
+			}
+			if got := c.backoff.Failures(); got != tt.failures {
+				t.Errorf("failures = %d, want %d", got, tt.failures)
+			}
+			if c.lastErr == nil {
+				t.Error("error not recorded")
+			}
+		})
+	}
+}
+
+func TestLoginFailedCanceled(t *testing.T) {
+	c := newTestClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.loginFailed(ctx, "Login failed", newLoginError("1", "密码错误"))
+	if !c.state.IsRunning() || c.lastErr != nil {
+		t.Error("a canceled login failure changed the client")
+	}
+}
@@ -0,0 +1,277 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/backoff"
+	"github.com/Rsplwe/ESurfingDialer/internal/cipher"
+	"github.com/Rsplwe/ESurfingDialer/internal/constants"
+	"github.com/Rsplwe/ESurfingDialer/internal/models"
+	"github.com/Rsplwe/ESurfingDialer/internal/network"
+	"github.com/Rsplwe/ESurfingDialer/internal/states"
+)
+
+// fakePortal answers probes with its config until a login and speaks the
+// ticket, auth, keep and term endpoints with a registered cipher
+type fakePortal struct {
+	*httptest.Server
+	algoID     string
+	impl       cipher.CipherInterface
+	logged     atomic.Bool
+	captive    atomic.Bool // Accept logins but keep redirecting
+	probes     atomic.Int32
+	logins     atomic.Int32
+	heartbeats atomic.Int32
+}
+
+func newFakePortal(t *testing.T) *fakePortal {
+	t.Helper()
+	algos := cipher.ListAlgorithms()
+	if len(algos) == 0 {
+		t.Fatal("no registered algorithm")
+	}
+	impl, err := cipher.GetInstance(algos[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakePortal{algoID: algos[0].ID, impl: impl}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/generate_204", func(w http.ResponseWriter, r *http.Request) {
+		p.probes.Add(1)
+		if p.logged.Load() {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		fmt.Fprintf(w, "<html>%s<config><auth-url>%s/auth</auth-url><ticket-url>%s/ticket?wlanuserip=10.0.0.2&amp;wlanacip=10.0.0.1</ticket-url></config>%s</html>",
+			constants.PortalStartTag, p.URL, p.URL, constants.PortalEndTag)
+	})
+	mux.HandleFunc("/ticket", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := p.decrypt(r); err != nil {
+			// The session request carries the plain algo id
+			w.Write(p.zsm())
+			return
+		}
+		p.reply(w, "<response><ticket>TICKET</ticket></response>")
+	})
+	mux.HandleFunc("/auth", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := p.decrypt(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.logins.Add(1)
+		p.logged.Store(!p.captive.Load())
+		p.reply(w, fmt.Sprintf("<response><rescode>0</rescode><keep-url>%s/keep</keep-url><term-url>%s/term</term-url><keep-retry>1</keep-retry></response>", p.URL, p.URL))
+	})
+	mux.HandleFunc("/keep", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := p.decrypt(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.heartbeats.Add(1)
+		p.reply(w, "<response><rescode>0</rescode><interval>1</interval></response>")
+	})
+	mux.HandleFunc("/term", func(w http.ResponseWriter, r *http.Request) {
+		p.logged.Store(false)
+		p.reply(w, "<response><rescode>0</rescode></response>")
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *fakePortal) zsm() []byte {
+	key := bytes.Repeat([]byte{0x42}, 16)
+	b := append([]byte("ZSM"), byte(len(key)))
+	b = append(b, key...)
+	b = append(b, byte(len(p.algoID)))
+	return append(b, p.algoID...)
+}
+
+func (p *fakePortal) decrypt(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return p.impl.Decrypt(body)
+}
+
+func (p *fakePortal) reply(w http.ResponseWriter, xml string) {
+	data, err := p.impl.Encrypt([]byte(xml))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(data)
+}
+
+// waitFor polls cond until it holds or the deadline passes
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newRunClient(t *testing.T, portal *fakePortal, policy backoff.Policy) *Client {
+	t.Helper()
+	st := states.New()
+	st.SetRunning(true)
+	c := New(&models.Options{
+		LoginUser:     "user",
+		LoginPassword: "password",
+		ProbeInterval: 10 * time.Millisecond,
+		Backoff:       policy,
+	}, st)
+	c.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	c.SetProbes(network.ProbeConfig{Probes: []network.Probe{{Name: "test", URL: portal.URL + "/generate_204"}}})
+	return c
+}
+
+// TestRun drives the client loop against a fake portal while Status and the
+// control requests are called concurrently, run it with -race
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for a heartbeat")
+	}
+	portal := newFakePortal(t)
+
+	c := newRunClient(t, portal, backoff.Policy{Initial: 10 * time.Millisecond, Max: 10 * time.Millisecond, Jitter: -1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel) // Stops the loop before the portal closes when the test fails
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Run(ctx)
+	}()
+
+	stop := make(chan struct{})
+	var pollers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		pollers.Add(1)
+		go func() {
+			defer pollers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				s := c.Status()
+				if s.LoggedIn && s.NextHeartbeat == nil {
+					t.Error("logged in without a next heartbeat")
+				}
+				c.HeartbeatFailures()
+				c.KeepRetry()
+			}
+		}()
+	}
+
+	waitFor(t, "the login", func() bool { return c.Status().LoggedIn })
+	waitFor(t, "a heartbeat", func() bool { return portal.heartbeats.Load() > 0 })
+
+	if err := c.RequestLogout(ctx); err != nil {
+		t.Fatalf("RequestLogout: %v", err)
+	}
+	if s := c.Status(); s.LoggedIn || !s.Paused {
+		t.Fatalf("after logout: logged in %v, paused %v", s.LoggedIn, s.Paused)
+	}
+	if portal.logged.Load() {
+		t.Fatal("logout did not terminate the session")
+	}
+
+	if err := c.RequestLogin(ctx); err != nil {
+		t.Fatalf("RequestLogin: %v", err)
+	}
+	waitFor(t, "the second login", func() bool { return c.Status().LoggedIn && !c.Status().Paused })
+
+	if err := c.RequestReconnect(ctx); err != nil {
+		t.Fatalf("RequestReconnect: %v", err)
+	}
+	if n := portal.logins.Load(); n < 3 {
+		t.Fatalf("%d logins, want at least 3", n)
+	}
+	if s := c.Status(); s.UserIP != "10.0.0.2" || s.ACIP != "10.0.0.1" || s.AlgoID != portal.algoID {
+		t.Fatalf("Status() = %+v", s)
+	}
+
+	close(stop)
+	pollers.Wait()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+// TestRunStillCaptive checks that a portal accepting logins but still
+// redirecting gets backed off instead of a login loop
+func TestRunStillCaptive(t *testing.T) {
+	portal := newFakePortal(t)
+	portal.captive.Store(true)
+	c := newRunClient(t, portal, backoff.Policy{Initial: 100 * time.Millisecond, Max: time.Second, Jitter: -1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	c.Run(ctx)
+
+	// Logins at 0, 100ms and 300ms, without the backoff there are hundreds
+	if n := portal.logins.Load(); n < 2 || n > 4 {
+		t.Errorf("%d logins in 500ms, want 2 to 4", n)
+	}
+	if s := c.Status(); s.RetryFail == 0 || s.LastError != ErrStillCaptive.Error() {
+		t.Errorf("Status() = %+v", s)
+	}
+}
+
+// TestCheckLiveness checks that a passing liveness check skips the portal
+// detection and a failed one falls back to it
+func TestCheckLiveness(t *testing.T) {
+	portal := newFakePortal(t)
+	c := newRunClient(t, portal, backoff.Policy{})
+	ctx := context.Background()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c.SetLiveness(network.Liveness{Mode: network.LivenessTCP, Target: ln.Addr().String()})
+
+	// Not logged in yet, the portal is detected
+	if err := c.Login(ctx); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	probes := portal.probes.Load()
+
+	if got := c.check(ctx); got != network.Success || portal.probes.Load() != probes {
+		t.Fatalf("check with the target up = %s after %d probes, want success without a probe", got, portal.probes.Load()-probes)
+	}
+
+	ln.Close()
+	if got := c.check(ctx); got != network.Success || portal.probes.Load() != probes+1 {
+		t.Fatalf("check with the target down = %s after %d probes, want success after one", got, portal.probes.Load()-probes)
+	}
+
+	portal.logged.Store(false)
+	if got := c.check(ctx); got != network.RequireAuthorization {
+		t.Fatalf("check behind the portal = %s, want require_authorization", got)
+	}
+}
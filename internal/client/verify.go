@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/network"
+)
+
+// defaultCodeCooldown is the minimum time between SMS code requests
+const defaultCodeCooldown = 60 * time.Second
+
+var (
+	// ErrCodeCooldown is returned by ResendCode before the cooldown has passed
+	ErrCodeCooldown = errors.New("SMS code was requested too recently")
+	// ErrVerifyCode is returned when the portal refused to send an SMS code
+	ErrVerifyCode = errors.New("SMS code request failed")
+)
+
+// checkSMSVerify asks for an SMS code when the portal requires one and waits for it,
+// it returns an empty code when none is required
+func (c *Client) checkSMSVerify(ctx context.Context) (string, error) {
+	if c.codeRejected {
+		c.logger.Info("The last SMS verification code was refused, requesting a new one")
+		res, err := c.ResendCode(ctx, true)
+		if err != nil {
+			return "", err
+		}
+		c.codeRejected = false
+		return c.codeFor(ctx, res)
+	}
+
+	required, err := network.VerifyRequired(ctx, c.httpClient, c.state, c.options.LoginUser)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		c.logger.Warn("Checking SMS verification failed, logging in without a code", "error", err)
+		return "", nil
+	}
+	if !required {
+		return "", nil
+	}
+
+	c.logger.Info("This login requires a SMS verification code")
+	res, err := c.ResendCode(ctx, true)
+	if err != nil {
+		return "", err
+	}
+	return c.codeFor(ctx, res)
+}
+
+// codeFor waits for the code sent by a code request
+func (c *Client) codeFor(ctx context.Context, res network.VerifyResult) (string, error) {
+	switch res.Status {
+	case network.VerifyNotRequired:
+		return "", nil
+	case network.VerifyError:
+		return "", fmt.Errorf("%w: %s (rescode %s)", ErrVerifyCode, res.Message, res.ResCode)
+	}
+	return c.readCode(ctx)
+}
+
+// ResendCode requests a new SMS verification code. Before the cooldown since the
+// last request has passed it fails with ErrCodeCooldown, or waits when wait is set.
+func (c *Client) ResendCode(ctx context.Context, wait bool) (network.VerifyResult, error) {
+	for {
+		remaining := c.reserveCode()
+		if remaining <= 0 {
+			break
+		}
+		if !wait {
+			return network.VerifyResult{}, fmt.Errorf("%w, retry in %s", ErrCodeCooldown, remaining.Round(time.Second))
+		}
+		c.logger.Info("Waiting for the SMS code cooldown", "remaining", remaining.Round(time.Second))
+		timer := time.NewTimer(remaining)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return network.VerifyResult{}, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return c.requestCode(ctx)
+}
+
+// requestCode asks the portal to send a code and records the result
+func (c *Client) requestCode(ctx context.Context) (network.VerifyResult, error) {
+	res, err := network.RequestVerifyCode(ctx, c.httpClient, c.state, c.options.LoginUser)
+	if ctx.Err() != nil {
+		return res, ctx.Err()
+	}
+
+	c.mu.Lock()
+	c.verify = res
+	c.verifyTime = time.Now()
+	c.mu.Unlock()
+
+	switch res.Status {
+	case network.VerifyCodeSent:
+		c.logger.Info("SMS verification code sent", "phone", res.Phone, "message", res.Message)
+	case network.VerifyRateLimited:
+		c.logger.Warn("SMS verification code rate limited, enter the last code received", "phone", res.Phone, "message", res.Message)
+	case network.VerifyError:
+		c.logger.Error("SMS verification code request failed", "rescode", res.ResCode, "message", res.Message, "error", err)
+	}
+	return res, nil
+}
+
+// reserveCode starts the cooldown and returns zero when it has passed, otherwise
+// the time left. Checking and starting it at once lets concurrent callers, such
+// as the control API and a login, send a single code.
+func (c *Client) reserveCode() time.Duration {
+	cooldown := c.options.CodeCooldown
+	if cooldown <= 0 {
+		cooldown = defaultCodeCooldown
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.codeTime.IsZero() {
+		if remaining := time.Until(c.codeTime.Add(cooldown)); remaining > 0 {
+			return remaining
+		}
+	}
+	c.codeTime = time.Now()
+	return 0
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/models"
+	"github.com/Rsplwe/ESurfingDialer/internal/network"
+	"github.com/Rsplwe/ESurfingDialer/internal/states"
+)
+
+// newVerifyClient returns a client whose code requests go to a server counting them
+func newVerifyClient(t *testing.T, cooldown time.Duration) (*Client, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		json.NewEncoder(w).Encode(models.ResponseRequireVerificate{ResCode: "0", Phone: "138****0000"})
+	}))
+	t.Cleanup(srv.Close)
+
+	st := states.New()
+	st.SetExtraCfgURL("QueryAuthCode", srv.URL)
+	c := New(&models.Options{LoginUser: "13800000000", CodeCooldown: cooldown}, st)
+	c.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return c, &requests
+}
+
+func TestResendCodeCooldown(t *testing.T) {
+	c, requests := newVerifyClient(t, time.Hour)
+
+	res, err := c.ResendCode(context.Background(), false)
+	if err != nil || res.Status != network.VerifyCodeSent || res.Phone != "138****0000" {
+		t.Fatalf("ResendCode() = %+v, %v", res, err)
+	}
+	if s := c.Status(); s.Verify == nil || s.Verify.Status != network.VerifyCodeSent {
+		t.Errorf("Status().Verify = %+v", s.Verify)
+	}
+
+	if _, err := c.ResendCode(context.Background(), false); !errors.Is(err, ErrCodeCooldown) {
+		t.Errorf("second ResendCode() error = %v, want ErrCodeCooldown", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.ResendCode(ctx, true); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waiting ResendCode() error = %v, want the deadline", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d code requests, want 1", n)
+	}
+}
+
+func TestResendCodeWaits(t *testing.T) {
+	c, requests := newVerifyClient(t, 50*time.Millisecond)
+	if _, err := c.ResendCode(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := c.ResendCode(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("the second request waited %s, want the cooldown", elapsed)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("%d code requests, want 2", n)
+	}
+}
+
+func TestResendCodeConcurrent(t *testing.T) {
+	c, requests := newVerifyClient(t, time.Hour)
+
+	var wg sync.WaitGroup
+	var sent, refused atomic.Int32
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.ResendCode(context.Background(), false)
+			switch {
+			case err == nil:
+				sent.Add(1)
+			case errors.Is(err, ErrCodeCooldown):
+				refused.Add(1)
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if requests.Load() != 1 || sent.Load() != 1 || refused.Load() != 7 {
+		t.Errorf("%d requests, %d sent, %d refused, want 1, 1 and 7", requests.Load(), sent.Load(), refused.Load())
+	}
+}
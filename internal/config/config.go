@@ -0,0 +1,327 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Environment variables consulted between flags and the config file
+const (
+	EnvConfig    = "ESURFING_CONFIG"
+	EnvProfile   = "ESURFING_PROFILE"
+	EnvUser      = "ESURFING_USER"
+	EnvPassword  = "ESURFING_PASSWORD"
+	EnvMAC       = "ESURFING_MAC"
+	EnvInterface = "ESURFING_INTERFACE"
+	EnvSMS       = "ESURFING_SMS_PROVIDER"
+	EnvProbes    = "ESURFING_PROBES"
+	EnvControl   = "ESURFING_CONTROL_TOKEN"
+)
+
+// File is a JSON configuration file holding named profiles
+//
+//	{
+//	  "default": "dorm",
+//	  "profiles": {
+//	    "dorm": {
+//	      "user": "13800000000",
+//	      "password": "secret",
+//	      "mac": "aa:bb:cc:dd:ee:ff",
+//	      "interface": "wan",
+//	      "timing": {
+//	        "probe_interval": "2s", "heartbeat_failures": 3,
+//	        "backoff": {"initial": "5s", "max": "5m", "multiplier": 2, "jitter": 0.2}
+//	      },
+//	      "sms": {"provider": "file:/tmp/esurfing.code", "timeout": "5m", "retries": 2, "cooldown": "60s"},
+//	      "probe": {
+//	        "targets": ["google", "http://example.com/ok|200|OK"], "parallel": true,
+//	        "liveness": "tcp", "liveness_target": "223.5.5.5:53", "liveness_interval": "10s"
+//	      }
+//	    }
+//	  }
+//	}
+type File struct {
+	Default  string             `json:"default"`
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// Profile holds the settings of one account
+type Profile struct {
+	User      string `json:"user"`
+	Password  string `json:"password"`
+	MAC       string `json:"mac"`
+	Interface string `json:"interface"`
+	Timing    Timing `json:"timing"`
+	SMS       SMS    `json:"sms"`
+	Probe     Probe  `json:"probe"`
+}
+
+// Timing overrides the client loop delays, zero values keep the defaults
+type Timing struct {
+	ProbeInterval Duration `json:"probe_interval"` // Delay between connectivity probes while online
+
+	// Deprecated: RetryDelay is an alias of backoff.initial, which takes precedence
+	RetryDelay Duration `json:"retry_delay"`
+
+	HeartbeatFailures int     `json:"heartbeat_failures"` // Consecutive failed heartbeats before logging in again
+	Backoff           Backoff `json:"backoff"`
+}
+
+// InitialDelay returns the first retry delay, backoff.initial or the deprecated retry_delay
+func (t Timing) InitialDelay() Duration {
+	if t.Backoff.Initial != 0 {
+		return t.Backoff.Initial
+	}
+	return t.RetryDelay
+}
+
+// Backoff grows the delay between consecutive failed probes and logins, zero values keep the defaults
+type Backoff struct {
+	Initial    Duration `json:"initial"`    // First delay
+	Max        Duration `json:"max"`        // Longest delay
+	Multiplier float64  `json:"multiplier"` // Growth per failure
+	Jitter     float64  `json:"jitter"`     // Random spread as a fraction of the delay, negative disables it
+}
+
+// SMS selects where verification codes come from, see smscode.Parse for providers
+type SMS struct {
+	Provider string    `json:"provider"` // stdin when empty
+	Timeout  *Duration `json:"timeout"`  // Per attempt, zero or nil waits forever
+	Retries  *int      `json:"retries"`  // Attempts after the first one, each requests a new code
+	Cooldown Duration  `json:"cooldown"` // Minimum time between code requests
+}
+
+// Attempts returns the number of attempts at getting a code, the first one and the retries
+func (s SMS) Attempts() int {
+	if s.Retries == nil {
+		return 1
+	}
+	return *s.Retries + 1
+}
+
+// Probe selects the connectivity check targets, see network.ParseProbe for specs
+type Probe struct {
+	Targets  []string `json:"targets"`  // Preset names or URLs tried in order, xiaomi when empty
+	Parallel *bool    `json:"parallel"` // Race the targets instead of trying them in order, nil when unset
+
+	// Cheap checks while logged in, the targets are only probed when one fails
+	Liveness         string    `json:"liveness"`          // http, tcp or dns, off when empty
+	LivenessTarget   string    `json:"liveness_target"`   // Probe spec, host:port or host name for the mode
+	LivenessInterval *Duration `json:"liveness_interval"` // Delay between checks, probe_interval when zero or nil
+}
+
+// IsParallel reports whether the targets are raced
+func (p Probe) IsParallel() bool {
+	return p.Parallel != nil && *p.Parallel
+}
+
+// Duration is a time.Duration written as a string such as "5s" in JSON
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"5s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if v < 0 {
+		return fmt.Errorf("duration %s is negative", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Value returns the duration of an optional setting, zero when it is unset
+func (d *Duration) Value() time.Duration {
+	if d == nil {
+		return 0
+	}
+	return time.Duration(*d)
+}
+
+// Load reads a configuration file
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Names returns the profile names sorted
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile returns a profile by name, an empty name selects the default
+// profile or the only profile in the file
+func (f *File) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = f.Default
+	}
+	if name == "" {
+		if len(f.Profiles) != 1 {
+			return Profile{}, fmt.Errorf("config has %d profiles and no default, choose one of: %s", len(f.Profiles), strings.Join(f.Names(), ", "))
+		}
+		name = f.Names()[0]
+	}
+	p, ok := f.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %q not found, available: %s", name, strings.Join(f.Names(), ", "))
+	}
+	return p, nil
+}
+
+// FromEnv returns the profile settings given by environment variables
+func FromEnv() Profile {
+	return Profile{
+		User:      os.Getenv(EnvUser),
+		Password:  os.Getenv(EnvPassword),
+		MAC:       os.Getenv(EnvMAC),
+		Interface: os.Getenv(EnvInterface),
+		SMS:       SMS{Provider: os.Getenv(EnvSMS)},
+		Probe:     Probe{Targets: SplitList(os.Getenv(EnvProbes))},
+	}
+}
+
+// Merge returns p with every non-empty setting of over applied on top. The
+// settings where zero differs from unset are pointers, a non-nil zero overrides.
+func (p Profile) Merge(over Profile) Profile {
+	if over.User != "" {
+		p.User = over.User
+	}
+	if over.Password != "" {
+		p.Password = over.Password
+	}
+	if over.MAC != "" {
+		p.MAC = over.MAC
+	}
+	if over.Interface != "" {
+		p.Interface = over.Interface
+	}
+	if over.Timing.ProbeInterval != 0 {
+		p.Timing.ProbeInterval = over.Timing.ProbeInterval
+	}
+	if over.Timing.RetryDelay != 0 {
+		p.Timing.RetryDelay = over.Timing.RetryDelay
+	}
+	if over.Timing.HeartbeatFailures != 0 {
+		p.Timing.HeartbeatFailures = over.Timing.HeartbeatFailures
+	}
+	if over.Timing.Backoff.Initial != 0 {
+		p.Timing.Backoff.Initial = over.Timing.Backoff.Initial
+	}
+	if over.Timing.Backoff.Max != 0 {
+		p.Timing.Backoff.Max = over.Timing.Backoff.Max
+	}
+	if over.Timing.Backoff.Multiplier != 0 {
+		p.Timing.Backoff.Multiplier = over.Timing.Backoff.Multiplier
+	}
+	if over.Timing.Backoff.Jitter != 0 {
+		p.Timing.Backoff.Jitter = over.Timing.Backoff.Jitter
+	}
+	if len(over.Probe.Targets) > 0 {
+		p.Probe.Targets = over.Probe.Targets
+	}
+	if over.Probe.Parallel != nil {
+		p.Probe.Parallel = over.Probe.Parallel
+	}
+	if over.Probe.Liveness != "" {
+		p.Probe.Liveness = over.Probe.Liveness
+	}
+	if over.Probe.LivenessTarget != "" {
+		p.Probe.LivenessTarget = over.Probe.LivenessTarget
+	}
+	if over.Probe.LivenessInterval != nil {
+		p.Probe.LivenessInterval = over.Probe.LivenessInterval
+	}
+	if over.SMS.Provider != "" {
+		p.SMS.Provider = over.SMS.Provider
+	}
+	if over.SMS.Timeout != nil {
+		p.SMS.Timeout = over.SMS.Timeout
+	}
+	if over.SMS.Retries != nil {
+		p.SMS.Retries = over.SMS.Retries
+	}
+	if over.SMS.Cooldown != 0 {
+		p.SMS.Cooldown = over.SMS.Cooldown
+	}
+	return p
+}
+
+// AccountSettings names the settings of p tied to one account, they cannot be
+// applied on top of several profiles
+func (p Profile) AccountSettings() []string {
+	var names []string
+	if p.User != "" {
+		names = append(names, "user")
+	}
+	if p.Password != "" {
+		names = append(names, "password")
+	}
+	if p.MAC != "" {
+		names = append(names, "mac")
+	}
+	if p.Interface != "" {
+		names = append(names, "interface")
+	}
+	return names
+}
+
+// SplitList splits a comma separated list, dropping empty items
+func SplitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Resolve builds the effective profile with precedence flags > env > file.
+// path and name fall back to ESURFING_CONFIG and ESURFING_PROFILE, no file is read when both are empty.
+func Resolve(path, name string, flags Profile) (Profile, error) {
+	if path == "" {
+		path = os.Getenv(EnvConfig)
+	}
+	if name == "" {
+		name = os.Getenv(EnvProfile)
+	}
+
+	var p Profile
+	if path != "" {
+		f, err := Load(path)
+		if err != nil {
+			return Profile{}, err
+		}
+		if p, err = f.Profile(name); err != nil {
+			return Profile{}, fmt.Errorf("config %s: %w", path, err)
+		}
+	} else if name != "" {
+		return Profile{}, fmt.Errorf("profile %q given without a config file", name)
+	}
+
+	return p.Merge(FromEnv()).Merge(flags), nil
+}
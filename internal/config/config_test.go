@@ -0,0 +1,147 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeParallel(t *testing.T) {
+	on, off := true, false
+	tests := []struct {
+		name string
+		base *bool
+		over *bool
+		want bool
+	}{
+		{"unset keeps unset", nil, nil, false},
+		{"unset keeps on", &on, nil, true},
+		{"on overrides unset", nil, &on, true},
+		{"off overrides on", &on, &off, false},
+		{"on overrides off", &off, &on, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Profile{Probe: Probe{Parallel: tt.base}}.Merge(Profile{Probe: Probe{Parallel: tt.over}})
+			if got := p.Probe.IsParallel(); got != tt.want {
+				t.Errorf("IsParallel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeKeepsUnset(t *testing.T) {
+	retries, interval := 2, Duration(time.Second)
+	base := Profile{
+		User:   "13800000000",
+		Timing: Timing{HeartbeatFailures: 5, Backoff: Backoff{Initial: Duration(1)}},
+		Probe:  Probe{Targets: []string{"google"}, Liveness: "tcp", LivenessInterval: &interval},
+		SMS:    SMS{Provider: "stdin", Retries: &retries},
+	}
+	if got := base.Merge(Profile{}); !reflect.DeepEqual(got, base) {
+		t.Errorf("Merge(empty) = %+v, want %+v", got, base)
+	}
+}
+
+func TestAccountSettings(t *testing.T) {
+	if got := (Profile{Timing: Timing{HeartbeatFailures: 3}, Probe: Probe{Liveness: "dns"}}).AccountSettings(); got != nil {
+		t.Errorf("AccountSettings() = %v, want none", got)
+	}
+	p := Profile{User: "u", Password: "p", MAC: "aa:bb:cc:dd:ee:ff", Interface: "wan"}
+	want := []string{"user", "password", "mac", "interface"}
+	if got := p.AccountSettings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("AccountSettings() = %v, want %v", got, want)
+	}
+}
+
+func TestMergeZero(t *testing.T) {
+	two, zero := 2, 0
+	timeout, noTimeout := Duration(time.Minute), Duration(0)
+	base := Profile{SMS: SMS{Timeout: &timeout, Retries: &two}}
+	if got := base.SMS.Attempts(); got != 3 {
+		t.Errorf("Attempts() = %d, want 3", got)
+	}
+	p := base.Merge(Profile{SMS: SMS{Timeout: &noTimeout, Retries: &zero}})
+	if p.SMS.Timeout.Value() != 0 || p.SMS.Attempts() != 1 {
+		t.Errorf("explicit zero did not override: timeout %s, attempts %d", p.SMS.Timeout.Value(), p.SMS.Attempts())
+	}
+	if got := (SMS{}).Attempts(); got != 1 {
+		t.Errorf("unset Attempts() = %d, want 1", got)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{
+		"default": "dorm",
+		"profiles": {
+			"dorm": {
+				"user": "file-user", "password": "file-password", "mac": "aa:aa:aa:aa:aa:aa", "interface": "file0",
+				"timing": {"heartbeat_failures": 5, "backoff": {"initial": "10s"}},
+				"sms": {"provider": "file:/tmp/code", "timeout": "5m", "retries": 2},
+				"probe": {"targets": ["google"], "parallel": true, "liveness_interval": "30s"}
+			},
+			"lab": {"user": "lab-user"}
+		}
+	}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, env := range []string{EnvConfig, EnvProfile, EnvUser, EnvPassword, EnvMAC, EnvInterface, EnvSMS, EnvProbes} {
+		t.Setenv(env, "")
+	}
+	t.Setenv(EnvPassword, "env-password")
+	t.Setenv(EnvMAC, "bb:bb:bb:bb:bb:bb")
+	t.Setenv(EnvSMS, "stdin")
+
+	off, zero, noTimeout := false, 0, Duration(0)
+	p, err := Resolve(path, "", Profile{
+		MAC:   "cc:cc:cc:cc:cc:cc",
+		Probe: Probe{Parallel: &off},
+		SMS:   SMS{Timeout: &noTimeout, Retries: &zero},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []struct {
+		name      string
+		got, want any
+	}{
+		{"user from the profile", p.User, "file-user"},
+		{"password from env over the profile", p.Password, "env-password"},
+		{"mac from the flag over env", p.MAC, "cc:cc:cc:cc:cc:cc"},
+		{"interface from the profile", p.Interface, "file0"},
+		{"sms provider from env", p.SMS.Provider, "stdin"},
+		{"heartbeat failures from the profile", p.Timing.HeartbeatFailures, 5},
+		{"backoff from the profile", p.Timing.InitialDelay(), Duration(10 * time.Second)},
+		{"default backoff max", p.Timing.Backoff.Max, Duration(0)},
+		{"targets from the profile", p.Probe.Targets, []string{"google"}},
+		{"liveness interval from the profile", p.Probe.LivenessInterval.Value(), 30 * time.Second},
+		{"parallel turned off by the flag", p.Probe.IsParallel(), false},
+		{"sms timeout reset by the flag", p.SMS.Timeout.Value(), time.Duration(0)},
+		{"sms retries reset by the flag", p.SMS.Attempts(), 1},
+	}
+	for _, c := range checks {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	// The path and profile fall back to the environment
+	t.Setenv(EnvConfig, path)
+	t.Setenv(EnvProfile, "lab")
+	if p, err := Resolve("", "", Profile{}); err != nil || p.User != "lab-user" || p.Password != "env-password" {
+		t.Errorf("Resolve from env = %+v, %v", p, err)
+	}
+	if _, err := Resolve("", "nosuchprofile", Profile{}); err == nil {
+		t.Error("Resolve of an unknown profile succeeded")
+	}
+
+	t.Setenv(EnvConfig, "")
+	if _, err := Resolve("", "lab", Profile{}); err == nil {
+		t.Error("Resolve of a profile without a config file succeeded")
+	}
+}
@@ -0,0 +1,258 @@
+// Package control serves a local HTTP API to inspect and drive running clients.
+//
+//	GET  /status               state of every instance
+//	POST /login?instance=NAME  log in now, resuming after a logout
+//	POST /logout?instance=NAME log out and stay offline
+//	POST /reconnect?instance=NAME
+//	POST /sms?instance=NAME    form value code answers an SMS prompt
+//	POST /sms/resend?instance=NAME
+//
+// The instance parameter may be omitted when only one client runs.
+//
+// Browsers must not be able to drive the API from another site, so POST
+// requests carry the X-ESurfing-Control header, which a cross-origin page
+// cannot set without a CORS preflight the server never answers. With a token
+// every request also needs "Authorization: Bearer TOKEN".
+//
+//	curl -X POST -H 'X-ESurfing-Control: 1' --unix-socket /run/esurfing.sock http://x/logout
+package control
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/client"
+)
+
+// requestTimeout bounds how long a control request waits for the client loop
+const requestTimeout = 30 * time.Second
+
+// Header is required on POST requests to keep browsers from forging them
+const Header = "X-ESurfing-Control"
+
+// Instance is a named client exposed by the API
+type Instance struct {
+	Name   string
+	Client *client.Client
+}
+
+// InstanceStatus is one entry of the /status response
+type InstanceStatus struct {
+	Name string `json:"name"`
+	client.Status
+}
+
+// Server is the control API
+type Server struct {
+	instances []Instance
+	token     string
+	mux       *http.ServeMux
+}
+
+// New creates a Server for the given instances, a non-empty token is required on every request
+func New(token string, instances ...Instance) *Server {
+	s := &Server{instances: instances, token: token, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/status", s.handleStatus)
+	s.mux.HandleFunc("/login", s.command((*client.Client).RequestLogin))
+	s.mux.HandleFunc("/logout", s.command((*client.Client).RequestLogout))
+	s.mux.HandleFunc("/reconnect", s.command((*client.Client).RequestReconnect))
+	s.mux.HandleFunc("/sms", s.handleSMS)
+	s.mux.HandleFunc("/sms/resend", s.handleResend)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" {
+		got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("missing or wrong token"))
+			return
+		}
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Header.Get(Header) == "" {
+		writeError(w, http.StatusForbidden, fmt.Errorf("missing %s header", Header))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Listen listens on addr, a "unix:" prefix selects a Unix socket path. TCP
+// addresses other than loopback are refused unless remote is set, the API
+// would otherwise be open to the whole network.
+func Listen(addr string, remote bool) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		if !remote && !isLoopback(addr) {
+			return nil, fmt.Errorf("refusing to serve on %s, which is not a loopback address, without a token", addr)
+		}
+		return net.Listen("tcp", addr)
+	}
+
+	// Remove a stale socket left by a previous run
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// isLoopback reports whether a host:port address only listens on loopback
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Serve serves the API on l until ctx is cancelled
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	srv := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+
+	name := r.URL.Query().Get("instance")
+	statuses := make([]InstanceStatus, 0, len(s.instances))
+	for _, in := range s.instances {
+		if name == "" || in.Name == name {
+			statuses = append(statuses, InstanceStatus{Name: in.Name, Status: in.Client.Status()})
+		}
+	}
+	if len(statuses) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown instance %q", name))
+		return
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// command returns a handler that runs fn on the selected instance
+func (s *Server) command(fn func(*client.Client, context.Context) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		in, ok := s.lookup(w, r)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		defer cancel()
+		if err := fn(in.Client, ctx); err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, InstanceStatus{Name: in.Name, Status: in.Client.Status()})
+	}
+}
+
+func (s *Server) handleSMS(w http.ResponseWriter, r *http.Request) {
+	in, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	if err := in.Client.SubmitCode(r.FormValue("code")); err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleResend(w http.ResponseWriter, r *http.Request) {
+	in, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+	defer cancel()
+	res, err := in.Client.ResendCode(ctx, false)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// lookup checks the method and finds the instance named by the request,
+// it writes an error response and returns false when there is none
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (Instance, bool) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return Instance{}, false
+	}
+
+	name := r.URL.Query().Get("instance")
+	if name == "" {
+		if len(s.instances) == 1 {
+			return s.instances[0], true
+		}
+		writeError(w, http.StatusBadRequest, errors.New("instance parameter is required"))
+		return Instance{}, false
+	}
+	for _, in := range s.instances {
+		if in.Name == name {
+			return in, true
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("unknown instance %q", name))
+	return Instance{}, false
+}
+
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, client.ErrNotLoggedIn):
+		return http.StatusConflict
+	case errors.Is(err, client.ErrCodeCooldown):
+		return http.StatusTooManyRequests
+	case errors.Is(err, client.ErrStopped):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,69 @@
+package control
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/client"
+	"github.com/Rsplwe/ESurfingDialer/internal/models"
+	"github.com/Rsplwe/ESurfingDialer/internal/states"
+)
+
+func newTestServer(token string) *Server {
+	c := client.New(&models.Options{LoginUser: "user"}, states.New())
+	return New(token, Instance{Name: "a", Client: c})
+}
+
+func TestServerRequestChecks(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		header map[string]string
+		want   int
+	}{
+		{"status without header", "", http.MethodGet, "/status", nil, http.StatusOK},
+		{"form post without header", "", http.MethodPost, "/sms?code=1234", nil, http.StatusForbidden},
+		{"post with header", "", http.MethodPost, "/sms", map[string]string{Header: "1"}, http.StatusConflict},
+		{"missing token", "secret", http.MethodGet, "/status", nil, http.StatusUnauthorized},
+		{"wrong token", "secret", http.MethodGet, "/status", map[string]string{"Authorization": "Bearer nope"}, http.StatusUnauthorized},
+		{"token", "secret", http.MethodGet, "/status", map[string]string{"Authorization": "Bearer secret"}, http.StatusOK},
+		{"token post without header", "secret", http.MethodPost, "/sms", map[string]string{"Authorization": "Bearer secret"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			newTestServer(tt.token).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestListenLoopbackOnly(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:0", "[::1]:0", "localhost:0"} {
+		l, err := Listen(addr, false)
+		if err != nil {
+			t.Fatalf("Listen(%q) = %v", addr, err)
+		}
+		l.Close()
+	}
+	for _, addr := range []string{":0", "0.0.0.0:0", "192.0.2.1:0"} {
+		if l, err := Listen(addr, false); err == nil {
+			l.Close()
+			t.Fatalf("Listen(%q) without a token succeeded", addr)
+		}
+	}
+	l, err := Listen("0.0.0.0:0", true)
+	if err != nil {
+		t.Fatalf("Listen with a token = %v", err)
+	}
+	l.Close()
+}
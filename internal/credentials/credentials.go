@@ -0,0 +1,94 @@
+package credentials
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// ErrNotFound is returned when no password is stored for a user
+var ErrNotFound = errors.New("credentials not found")
+
+// Store keeps login passwords outside of argv and config files
+type Store interface {
+	Get(user string) (string, error)
+	Set(user, password string) error
+	Name() string
+}
+
+// Backend names accepted by Open
+const (
+	BackendAuto          = "auto"
+	BackendSecretService = "secret-service"
+	BackendFile          = "file"
+)
+
+// Open returns the store for a backend, auto uses the Secret Service when
+// secret-tool is installed and falls back to the encrypted file when it is
+// not or when it fails, such as without a D-Bus session under a service manager.
+// An empty path selects the default credentials file.
+func Open(backend, path string) (Store, error) {
+	switch backend {
+	case "", BackendAuto:
+		file, err := NewFileStore(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := exec.LookPath(secretTool); err == nil {
+			return &fallback{primary: &SecretService{}, secondary: file}, nil
+		}
+		return file, nil
+	case BackendSecretService:
+		if _, err := exec.LookPath(secretTool); err != nil {
+			return nil, errors.New("secret-tool not found, install libsecret-tools or use the file backend")
+		}
+		return &SecretService{}, nil
+	case BackendFile:
+		return NewFileStore(path)
+	default:
+		return nil, errors.New("unknown credentials backend: " + backend)
+	}
+}
+
+// fallback uses secondary when primary fails or has no password for a user
+type fallback struct {
+	primary   Store
+	secondary Store
+	used      Store // Store of the last successful Set
+}
+
+func (f *fallback) Name() string {
+	if f.used != nil {
+		return f.used.Name()
+	}
+	return f.primary.Name()
+}
+
+func (f *fallback) Get(user string) (string, error) {
+	password, err := f.primary.Get(user)
+	if err == nil {
+		return password, nil
+	}
+	password, err2 := f.secondary.Get(user)
+	if err2 == nil {
+		return password, nil
+	}
+	if errors.Is(err, ErrNotFound) {
+		return "", err2
+	}
+	if errors.Is(err2, ErrNotFound) {
+		return "", err
+	}
+	return "", errors.Join(err, err2)
+}
+
+func (f *fallback) Set(user, password string) error {
+	if err := f.primary.Set(user, password); err != nil {
+		if err2 := f.secondary.Set(user, password); err2 != nil {
+			return errors.Join(err, err2)
+		}
+		f.used = f.secondary
+		return nil
+	}
+	f.used = f.primary
+	return nil
+}
@@ -0,0 +1,125 @@
+package credentials
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStoreRandomKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	f := &FileStore{Path: path}
+	if err := f.Set("user", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(path + ".key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 || fi.Size() != keySize {
+		t.Fatalf("key file mode %v size %d", fi.Mode().Perm(), fi.Size())
+	}
+
+	if got, err := f.Get("user"); err != nil || got != "secret" {
+		t.Fatalf("Get() = %q, %v", got, err)
+	}
+	if _, err := f.Get("other"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(other) error = %v, want ErrNotFound", err)
+	}
+
+	// Without its key the file cannot be read, whatever the hostname
+	other := &FileStore{Path: path, KeyPath: filepath.Join(t.TempDir(), "other.key")}
+	if err := os.WriteFile(other.KeyPath, make([]byte, keySize), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Get("user"); err == nil {
+		t.Fatal("Get() with another key succeeded")
+	}
+}
+
+func TestFileStoreLegacyKey(t *testing.T) {
+	if _, err := legacyKey(); err != nil {
+		t.Skip("no machine id or hostname:", err)
+	}
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+
+	// Write a file the way older versions did
+	key, _ := legacyKey()
+	gcm, err := newGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if err := os.WriteFile(path, gcm.Seal(nonce, nonce, []byte(`{"user":"old"}`), nil), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &FileStore{Path: path}
+	if got, err := f.Get("user"); err != nil || got != "old" {
+		t.Fatalf("Get() = %q, %v", got, err)
+	}
+	// The next write moves the file to a random key
+	if err := f.Set("user2", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".key"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := f.Get("user"); err != nil || got != "old" {
+		t.Fatalf("Get() after migration = %q, %v", got, err)
+	}
+}
+
+// memStore is a Store backed by a map, err fails every call
+type memStore struct {
+	name    string
+	entries map[string]string
+	err     error
+}
+
+func (m *memStore) Name() string { return m.name }
+
+func (m *memStore) Get(user string) (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
+	p, ok := m.entries[user]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return p, nil
+}
+
+func (m *memStore) Set(user, password string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.entries[user] = password
+	return nil
+}
+
+func TestFallback(t *testing.T) {
+	noBus := errors.New("secret-tool lookup: exit status 1: Cannot autolaunch D-Bus")
+	broken := &memStore{name: "secret-service", err: noBus}
+	file := &memStore{name: "file", entries: map[string]string{"user": "pw"}}
+	f := &fallback{primary: broken, secondary: file}
+
+	if got, err := f.Get("user"); err != nil || got != "pw" {
+		t.Fatalf("Get() = %q, %v", got, err)
+	}
+	// The primary failure is reported instead of a plain not found
+	if _, err := f.Get("other"); !errors.Is(err, noBus) {
+		t.Fatalf("Get(other) error = %v, want the secret service error", err)
+	}
+	if err := f.Set("new", "pw2"); err != nil || file.entries["new"] != "pw2" || f.Name() != "file" {
+		t.Fatalf("Set() = %v, stored in %s", err, f.Name())
+	}
+
+	working := &memStore{name: "secret-service", entries: map[string]string{}}
+	f = &fallback{primary: working, secondary: file}
+	if _, err := f.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,209 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileStore keeps passwords in an AES-GCM encrypted file for headless routers
+// without a keyring. The key is a random secret generated on first use and kept
+// in a separate 0600 file next to it, so the passwords are only as safe as that
+// key file. The encryption keeps them out of a copied or backed up credentials
+// file, it does not protect them from someone who can read both files.
+type FileStore struct {
+	Path    string
+	KeyPath string // Path + ".key" when empty
+}
+
+// DefaultFilePath returns the credentials file under the user config directory,
+// or under /etc when there is no home directory (e.g. procd services)
+func DefaultFilePath() string {
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, "esurfing", "credentials.enc")
+	}
+	return "/etc/esurfing/credentials.enc"
+}
+
+// NewFileStore creates a file store, an empty path selects DefaultFilePath
+func NewFileStore(path string) (*FileStore, error) {
+	if path == "" {
+		path = DefaultFilePath()
+	}
+	return &FileStore{Path: path}, nil
+}
+
+func (f *FileStore) keyPath() string {
+	if f.KeyPath != "" {
+		return f.KeyPath
+	}
+	return f.Path + ".key"
+}
+
+func (f *FileStore) Name() string {
+	return BackendFile
+}
+
+func (f *FileStore) Get(user string) (string, error) {
+	entries, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	password, ok := entries[user]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return password, nil
+}
+
+func (f *FileStore) Set(user, password string) error {
+	entries, err := f.load()
+	if err != nil {
+		return err
+	}
+	entries[user] = password
+	return f.save(entries)
+}
+
+func (f *FileStore) load() (map[string]string, error) {
+	entries := make(map[string]string)
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := f.readKey()
+	if errors.Is(err, os.ErrNotExist) {
+		// Files written before the key file existed used a key derived from the machine id
+		key, err = legacyKey()
+	}
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("credentials file %s is truncated", f.Path)
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("credentials file %s cannot be decrypted with key %s", f.Path, f.keyPath())
+	}
+	if err := json.Unmarshal(plain, &entries); err != nil {
+		return nil, fmt.Errorf("credentials file %s: %w", f.Path, err)
+	}
+	return entries, nil
+}
+
+func (f *FileStore) save(entries map[string]string) error {
+	plain, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0700); err != nil {
+		return err
+	}
+	key, err := f.readKey()
+	if errors.Is(err, os.ErrNotExist) {
+		key, err = f.createKey()
+	}
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	data := gcm.Seal(nonce, nonce, plain, nil)
+
+	// Write to a temporary file first so a failed write keeps the old credentials
+	tmp := f.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.Path)
+}
+
+// readKey reads the key file
+func (f *FileStore) readKey() ([]byte, error) {
+	key, err := os.ReadFile(f.keyPath())
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("credentials key %s has %d bytes, want %d", f.keyPath(), len(key), keySize)
+	}
+	return key, nil
+}
+
+// createKey generates a random key and writes it readable by the owner only
+func (f *FileStore) createKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	// O_EXCL keeps a concurrent writer from replacing a key already in use
+	file, err := os.OpenFile(f.keyPath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(key); err != nil {
+		file.Close()
+		os.Remove(f.keyPath())
+		return nil, err
+	}
+	return key, file.Close()
+}
+
+// keySize is the AES-256 key size
+const keySize = 32
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// legacyKey derives the key older versions used from the machine id or hostname
+func legacyKey() ([]byte, error) {
+	id, err := machineID()
+	if err != nil {
+		return nil, err
+	}
+	key := sha256.Sum256([]byte("esurfing-dialer credentials\x00" + id))
+	return key[:], nil
+}
+
+func machineID() (string, error) {
+	for _, path := range []string{"/etc/machine-id", "/var/lib/dbus/machine-id"} {
+		if data, err := os.ReadFile(path); err == nil {
+			if id := strings.TrimSpace(string(data)); id != "" {
+				return id, nil
+			}
+		}
+	}
+	// OpenWrt and other minimal systems have no machine id
+	host, err := os.Hostname()
+	if err != nil {
+		return "", fmt.Errorf("no machine id or hostname to derive the credentials key: %w", err)
+	}
+	return host, nil
+}
@@ -0,0 +1,58 @@
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const (
+	secretTool    = "secret-tool"
+	secretService = "esurfing-dialer"
+)
+
+// SecretService stores passwords in the desktop keyring through libsecret's secret-tool
+type SecretService struct{}
+
+func (s *SecretService) Name() string {
+	return BackendSecretService
+}
+
+func (s *SecretService) Get(user string) (string, error) {
+	cmd := exec.Command(secretTool, "lookup", "service", secretService, "user", user)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		msg := strings.TrimSpace(stderr.String())
+		// secret-tool exits with 1 and no output for a missing item, anything
+		// else such as a missing D-Bus session under a service manager is a failure
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && len(out) == 0 && msg == "" {
+			return "", ErrNotFound
+		}
+		if msg != "" {
+			return "", fmt.Errorf("secret-tool lookup: %v: %s", err, msg)
+		}
+		return "", fmt.Errorf("secret-tool lookup: %w", err)
+	}
+	password := strings.TrimRight(string(out), "\r\n")
+	if password == "" {
+		return "", ErrNotFound
+	}
+	return password, nil
+}
+
+func (s *SecretService) Set(user, password string) error {
+	cmd := exec.Command(secretTool, "store", "--label", "ESurfing Dialer ("+user+")", "service", secretService, "user", user)
+	// The password is passed on stdin so it never shows up in argv
+	cmd.Stdin = strings.NewReader(password)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("secret-tool store: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
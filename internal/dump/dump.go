@@ -0,0 +1,52 @@
+package dump
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/cipher"
+	"github.com/Rsplwe/ESurfingDialer/internal/session"
+)
+
+// Dump is a parsed algo_dump_*.bin file, the raw ZSM blob returned by the ticket URL
+type Dump struct {
+	*session.ZSM
+}
+
+// Parse parses the ZSM blob of a dump
+func Parse(data []byte) (*Dump, error) {
+	zsm, err := session.ParseZSM(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Dump{ZSM: zsm}, nil
+}
+
+// Inspect writes a human readable report of a dump to w
+func Inspect(w io.Writer, data []byte) error {
+	d, err := Parse(data)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Type:           %s\n", d.Header)
+	fmt.Fprintf(w, "Key Length:     %d\n", len(d.Key))
+	fmt.Fprintf(w, "Key:            %q\n", d.Key)
+	fmt.Fprintf(w, "Algo Id Length: %d\n", len(d.AlgoID))
+	fmt.Fprintf(w, "Algo Id:        %s\n", d.AlgoID)
+
+	if algo, ok := cipher.Lookup(d.AlgoID); ok {
+		fmt.Fprintf(w, "Algorithm:      %s (%s), already supported\n", algo.Name, algo.Type)
+	} else {
+		// Algo-IDs are random GUIDs and the cipher keys ship inside the official
+		// client, nothing in the blob hints at the cipher family
+		fmt.Fprintf(w, "Algorithm:      unknown, the cipher cannot be inferred from the dump\n")
+	}
+
+	fmt.Fprintf(w, "Payload:        %d bytes\n", len(d.Payload))
+	if len(d.Payload) > 0 {
+		fmt.Fprint(w, hex.Dump(d.Payload))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package dump
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/session"
+)
+
+// dumpBlob builds a ZSM blob shaped like the ones the ticket URL returns
+func dumpBlob(algoID string) []byte {
+	key := []byte("k3Y-0f-16-bytes!")
+	b := append([]byte("ZSM"), byte(len(key)))
+	b = append(b, key...)
+	b = append(b, byte(len(algoID)))
+	return append(b, algoID...)
+}
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		name   string
+		algoID string
+		want   []string
+	}{
+		{"supported", "CAFBCBAD-B6E7-4CAB-8A67-14D39F00CE1E", []string{
+			"Type:           ZSM",
+			"Key Length:     16",
+			"Algo Id Length: 36",
+			"AES/CBC/NoPadding",
+			"already supported",
+			"Payload:        0 bytes",
+		}},
+		{"unknown", "0D1E2F3A-4B5C-4D6E-8F70-8192A3B4C5D6", []string{
+			"Algo Id:        0D1E2F3A-4B5C-4D6E-8F70-8192A3B4C5D6",
+			"Algorithm:      unknown, the cipher cannot be inferred from the dump",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Inspect(&out, dumpBlob(tt.algoID)); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("report is missing %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestInspectMalformed(t *testing.T) {
+	if err := Inspect(&bytes.Buffer{}, []byte("ZSM")); !errors.Is(err, session.ErrMalformedZSM) {
+		t.Fatalf("Inspect() error = %v, want ErrMalformedZSM", err)
+	}
+}
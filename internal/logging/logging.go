@@ -0,0 +1,142 @@
+// Package logging builds the slog loggers used by the dialer, with secret
+// redaction and an optional rotating log file.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Redacted replaces the value of secret attributes
+const Redacted = "[REDACTED]"
+
+// secretKeys are attribute keys whose values are never written
+var secretKeys = map[string]bool{
+	"password": true,
+	"passwd":   true,
+	"ticket":   true,
+	"key":      true,
+	"code":     true,
+	"keep_url": true,
+	"term_url": true,
+}
+
+// Options configures New
+type Options struct {
+	Level      string // debug, info, warn or error
+	Format     string // text or json
+	File       string // Log file path, empty writes to stdout
+	MaxSize    int64  // Bytes before the log file rotates, 0 never rotates
+	MaxBackups int    // Rotated files kept
+}
+
+// New creates a logger from opts, the closer releases the log file
+func New(opts Options) (*slog.Logger, io.Closer, error) {
+	var level slog.Level
+	if opts.Level != "" {
+		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+			return nil, nil, fmt.Errorf("invalid log level %q", opts.Level)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	var closer io.Closer = nopCloser{}
+	if opts.File != "" {
+		f, err := OpenRotatingFile(opts.File, opts.MaxSize, opts.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		w, closer = f, f
+	}
+
+	h, err := NewHandler(w, opts.Format, level)
+	if err != nil {
+		closer.Close()
+		return nil, nil, err
+	}
+	return slog.New(h), closer, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// NewHandler creates a text or json handler that redacts secrets
+func NewHandler(w io.Writer, format string, level slog.Leveler) (slog.Handler, error) {
+	ho := &slog.HandlerOptions{Level: level, ReplaceAttr: Redact}
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return slog.NewTextHandler(w, ho), nil
+	case FormatJSON:
+		return slog.NewJSONHandler(w, ho), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+}
+
+// Redact is a slog.HandlerOptions.ReplaceAttr func that hides the values
+// of passwords, tickets, keys, verification codes and session URLs
+func Redact(groups []string, a slog.Attr) slog.Attr {
+	if secretKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+// RedactHandler wraps h so that secret attributes are redacted whatever its
+// options, for handlers supplied by library users
+func RedactHandler(h slog.Handler) slog.Handler {
+	if _, ok := h.(redactHandler); ok {
+		return h
+	}
+	return redactHandler{h}
+}
+
+type redactHandler struct {
+	slog.Handler
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return redactHandler{h.Handler.WithAttrs(redacted)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{h.Handler.WithGroup(name)}
+}
+
+// redactAttr applies Redact to a and every attribute of its groups
+func redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup {
+		return Redact(nil, a)
+	}
+	group := a.Value.Group()
+	redacted := make([]slog.Attr, len(group))
+	for i, ga := range group {
+		redacted[i] = redactAttr(ga)
+	}
+	return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+}
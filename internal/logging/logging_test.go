@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	for key := range secretKeys {
+		for _, k := range []string{key, strings.ToUpper(key), strings.ToUpper(key[:1]) + key[1:]} {
+			a := Redact(nil, slog.String(k, "secret"))
+			if a.Key != k || a.Value.String() != Redacted {
+				t.Errorf("Redact(%q) = %v", k, a)
+			}
+			if a := Redact([]string{"group"}, slog.String(k, "secret")); a.Value.String() != Redacted {
+				t.Errorf("Redact(%q) in a group = %v", k, a)
+			}
+		}
+	}
+	for _, k := range []string{"user", "error", "algo_id", "keep_retry", "key_length"} {
+		if a := Redact(nil, slog.String(k, "value")); a.Value.String() != "value" {
+			t.Errorf("Redact(%q) = %v, want it kept", k, a)
+		}
+	}
+}
+
+func TestHandlerRedacts(t *testing.T) {
+	for _, format := range []string{FormatText, FormatJSON} {
+		var b bytes.Buffer
+		h, err := NewHandler(&b, format, slog.LevelDebug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger := slog.New(h).With("Password", "hunter2")
+		logger.Info("test", slog.Group("login", "ticket", "T1CKET", "user", "alice"), "Code", "123456")
+		out := b.String()
+		for _, secret := range []string{"hunter2", "T1CKET", "123456"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("%s output leaks %q: %s", format, secret, out)
+			}
+		}
+		if !strings.Contains(out, "alice") {
+			t.Errorf("%s output lost a plain attribute: %s", format, out)
+		}
+	}
+}
+
+func TestNewHandlerFormat(t *testing.T) {
+	if _, err := NewHandler(&bytes.Buffer{}, "xml", slog.LevelInfo); err == nil {
+		t.Error("NewHandler accepted an unknown format")
+	}
+}
+
+func TestRedactHandler(t *testing.T) {
+	var b bytes.Buffer
+	// A handler without Redact, as a library user may supply
+	logger := slog.New(RedactHandler(slog.NewJSONHandler(&b, nil))).With("KEY", "0xdeadbeef")
+	logger.WithGroup("session").Info("test",
+		"ticket", "T1CKET",
+		slog.Group("login", "Passwd", "hunter2", slog.Group("inner", "code", "123456")),
+		"user", "alice",
+	)
+	out := b.String()
+	for _, secret := range []string{"0xdeadbeef", "T1CKET", "hunter2", "123456"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("output leaks %q: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "alice") || strings.Count(out, Redacted) != 4 {
+		t.Errorf("output = %s", out)
+	}
+	if h := RedactHandler(logger.Handler()); h != logger.Handler() {
+		t.Error("RedactHandler wrapped a redacting handler again")
+	}
+}
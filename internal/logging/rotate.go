@@ -0,0 +1,125 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an append-only log file that is renamed to PATH.1 once it
+// grows past its maximum size, older files shift up to PATH.N
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	failed     bool        // The last rotation failed, its error was reported
+	report     func(error) // Reports a failed rotation, stderr by default
+}
+
+// OpenRotatingFile opens path for appending, maxSize 0 disables rotation
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = fi.Size()
+	return nil
+}
+
+// Write appends p, rotating first when p would exceed the maximum size
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		r.rotate()
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the backups and starts a new file, r.mu must be held. When it
+// fails the current file is kept, the error is reported once and rotating is
+// tried again after another maxSize bytes.
+func (r *RotatingFile) rotate() {
+	err := r.shift()
+	if err == nil {
+		old := r.file
+		if err = r.open(); err == nil {
+			old.Close()
+		}
+	}
+	if err != nil {
+		if !r.failed {
+			r.failed = true
+			r.reportError(err)
+		}
+		r.size = 0
+		return
+	}
+	r.failed = false
+}
+
+// shift moves the current file to PATH.1 and each backup up by one, dropping
+// the oldest. Without backups the current file is truncated instead.
+func (r *RotatingFile) shift() error {
+	if r.maxBackups <= 0 {
+		return r.file.Truncate(0)
+	}
+	if err := os.Remove(r.backup(r.maxBackups)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for i := r.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(r.backup(i), r.backup(i+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return os.Rename(r.path, r.backup(1))
+}
+
+func (r *RotatingFile) reportError(err error) {
+	if r.report != nil {
+		r.report(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "log rotation of %s failed, writing to the current file: %v\n", r.path, err)
+}
+
+func (r *RotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
+
+// Close closes the file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,136 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func writeLines(t *testing.T, r *RotatingFile, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRotatingFileRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dialer.log")
+	r, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// Each line is 6 bytes, two do not fit in 10
+	writeLines(t, r, "line1\n", "line2\n", "line3\n", "line4\n")
+
+	if got := readFile(t, path); got != "line4\n" {
+		t.Errorf("current = %q", got)
+	}
+	if got := readFile(t, path+".1"); got != "line3\n" {
+		t.Errorf("backup 1 = %q", got)
+	}
+	if got := readFile(t, path+".2"); got != "line2\n" {
+		t.Errorf("backup 2 = %q", got)
+	}
+	// line1 was shifted past maxBackups
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("backup 3 exists: %v", err)
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dialer.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenRotatingFile(path, 100, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, r, "new\n")
+	r.Close()
+	if got := readFile(t, path); got != "old\nnew\n" {
+		t.Errorf("file = %q", got)
+	}
+	if _, err := r.Write([]byte("closed\n")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
+
+func TestRotatingFileNoBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dialer.log")
+	r, err := OpenRotatingFile(path, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	writeLines(t, r, "line1\n", "line2\n")
+	if got := readFile(t, path); got != "line2\n" {
+		t.Errorf("current = %q", got)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("backup 1 exists: %v", err)
+	}
+}
+
+func TestRotatingFileNoLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dialer.log")
+	r, err := OpenRotatingFile(path, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	writeLines(t, r, strings.Repeat("x", 100), strings.Repeat("y", 100))
+	if got := readFile(t, path); len(got) != 200 {
+		t.Errorf("current has %d bytes, want 200", len(got))
+	}
+}
+
+func TestRotatingFileRotationFails(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dialer.log")
+	// A non-empty directory in the way of the first backup makes the rename fail
+	if err := os.MkdirAll(filepath.Join(path+".1", "blocked"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var reported []error
+	r.report = func(err error) { reported = append(reported, err) }
+
+	writeLines(t, r, "line1\n", "line2\n", "line3\n", "line4\n", "line5\n")
+	if len(reported) != 1 {
+		t.Fatalf("reported %d errors, want 1: %v", len(reported), reported)
+	}
+	if got := readFile(t, path); got != "line1\nline2\nline3\nline4\nline5\n" {
+		t.Errorf("current = %q, want every line kept", got)
+	}
+
+	// Rotation resumes once the obstacle is gone
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, r, "line6\n", "line7\n", "line8\n")
+	if got := readFile(t, path); got != "line8\n" {
+		t.Errorf("current after recovery = %q", got)
+	}
+	if got := readFile(t, path+".1"); !strings.HasSuffix(got, "line7\n") {
+		t.Errorf("backup after recovery = %q", got)
+	}
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Default is the registry the dialer metrics are registered in
+var Default = NewRegistry()
+
+var (
+	loginAttempts = NewCounterVec("esurfing_login_attempts_total",
+		"Portal logins attempted.", "instance")
+	loginSuccesses = NewCounterVec("esurfing_login_successes_total",
+		"Portal logins that were authorized.", "instance")
+	loginFailures = NewCounterVec("esurfing_login_failures_total",
+		"Portal logins that failed.", "instance")
+	heartbeats = NewCounterVec("esurfing_heartbeats_total",
+		"Heartbeats sent to the keep URL.", "instance")
+	heartbeatFailures = NewCounterVec("esurfing_heartbeat_failures_total",
+		"Heartbeats that failed.", "instance")
+	connectivityChecks = NewCounterVec("esurfing_connectivity_checks_total",
+		"Connectivity checks by outcome.", "instance", "status")
+	keepRetry = NewGaugeVec("esurfing_keep_retry_seconds",
+		"Heartbeat interval requested by the portal.", "instance")
+	loggedIn = NewGaugeVec("esurfing_logged_in",
+		"Whether the client holds an authorized session.", "instance")
+	sessionUptime = NewGaugeVec("esurfing_session_uptime_seconds",
+		"Time since the current session was authorized, 0 when logged out.", "instance")
+	requestDuration = NewHistogramVec("esurfing_http_request_duration_seconds",
+		"Latency of portal requests by endpoint.", DefaultBuckets, "instance", "endpoint")
+)
+
+func init() {
+	Default.MustRegister(
+		loginAttempts, loginSuccesses, loginFailures,
+		heartbeats, heartbeatFailures, connectivityChecks,
+		keepRetry, loggedIn, sessionUptime, requestDuration,
+	)
+}
+
+// Portal endpoints whose request latency is recorded
+const (
+	EndpointTicket = "ticket"
+	EndpointAuth   = "auth"
+	EndpointKeep   = "keep"
+	EndpointTerm   = "term"
+)
+
+// Dialer records the metrics of one client, a nil *Dialer records nothing
+type Dialer struct {
+	instance     string
+	sessionStart atomic.Int64 // Unix nanoseconds, 0 when logged out
+}
+
+// NewDialer creates the metrics of the client named instance
+func NewDialer(instance string) *Dialer {
+	d := &Dialer{instance: instance}
+	loggedIn.WithFunc(func() float64 {
+		if d.sessionStart.Load() == 0 {
+			return 0
+		}
+		return 1
+	}, instance)
+	sessionUptime.WithFunc(func() float64 {
+		start := d.sessionStart.Load()
+		if start == 0 {
+			return 0
+		}
+		return time.Since(time.Unix(0, start)).Seconds()
+	}, instance)
+	return d
+}
+
+// LoginAttempted counts a login attempt
+func (d *Dialer) LoginAttempted() {
+	if d != nil {
+		loginAttempts.With(d.instance).Inc()
+	}
+}
+
+// LoginDone counts the result of a login attempt and starts the session uptime on success
+func (d *Dialer) LoginDone(err error) {
+	if d == nil {
+		return
+	}
+	if err != nil {
+		loginFailures.With(d.instance).Inc()
+		return
+	}
+	loginSuccesses.With(d.instance).Inc()
+	d.sessionStart.Store(time.Now().UnixNano())
+}
+
+// LoggedOut resets the session uptime
+func (d *Dialer) LoggedOut() {
+	if d != nil {
+		d.sessionStart.Store(0)
+	}
+}
+
+// HeartbeatDone counts a heartbeat and its failure
+func (d *Dialer) HeartbeatDone(err error) {
+	if d == nil {
+		return
+	}
+	heartbeats.With(d.instance).Inc()
+	if err != nil {
+		heartbeatFailures.With(d.instance).Inc()
+	}
+}
+
+// Connectivity counts a connectivity check outcome
+func (d *Dialer) Connectivity(status string) {
+	if d != nil {
+		connectivityChecks.With(d.instance, status).Inc()
+	}
+}
+
+// KeepRetry records the heartbeat interval
+func (d *Dialer) KeepRetry(interval time.Duration) {
+	if d != nil {
+		keepRetry.With(d.instance).Set(interval.Seconds())
+	}
+}
+
+// Request records the latency of a request to endpoint that started at start
+func (d *Dialer) Request(endpoint string, start time.Time) {
+	if d != nil {
+		requestDuration.With(d.instance, endpoint).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the Default registry
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Default.WriteText(w)
+	})
+}
+
+// Serve serves /metrics on l until ctx is cancelled
+func Serve(ctx context.Context, l net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+// Package metrics implements the counters, gauges and histograms exported
+// by the dialer in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBuckets are the histogram upper bounds in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	bits atomic.Uint64
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add adds v, which must not be negative, to the counter
+func (c *Counter) Add(v float64) { addFloat(&c.bits, v) }
+
+// Value returns the counter value
+func (c *Counter) Value() float64 { return math.Float64frombits(c.bits.Load()) }
+
+// Gauge is a value that can go up and down, or is computed when scraped
+type Gauge struct {
+	bits atomic.Uint64
+	fn   func() float64
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64) { g.bits.Store(math.Float64bits(v)) }
+
+// Value returns the gauge value
+func (g *Gauge) Value() float64 {
+	if g.fn != nil {
+		return g.fn()
+	}
+	return math.Float64frombits(g.bits.Load())
+}
+
+// Histogram counts observations into buckets
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64 // Per bucket, not cumulative
+	count   uint64
+	sum     float64
+}
+
+// Observe records one observation
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func addFloat(bits *atomic.Uint64, v float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+// family holds the children of one metric name keyed by label values
+type family struct {
+	name     string
+	help     string
+	kind     string
+	labels   []string
+	mu       sync.Mutex
+	children map[string]any
+	values   map[string][]string
+}
+
+func newFamily(name, help, kind string, labels []string) *family {
+	return &family{
+		name:     name,
+		help:     help,
+		kind:     kind,
+		labels:   labels,
+		children: make(map[string]any),
+		values:   make(map[string][]string),
+	}
+}
+
+// child returns the metric for values, creating it with create
+func (f *family) child(values []string, create func() any) any {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, ok := f.children[key]
+	if !ok {
+		m = create()
+		f.children[key] = m
+		f.values[key] = append([]string(nil), values...)
+	}
+	return m
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct{ f *family }
+
+// NewCounterVec creates a CounterVec, it must be registered to be exported
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{newFamily(name, help, "counter", labels)}
+}
+
+// With returns the counter for the label values
+func (v *CounterVec) With(values ...string) *Counter {
+	return v.f.child(values, func() any { return &Counter{} }).(*Counter)
+}
+
+// GaugeVec is a gauge partitioned by labels
+type GaugeVec struct{ f *family }
+
+// NewGaugeVec creates a GaugeVec, it must be registered to be exported
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{newFamily(name, help, "gauge", labels)}
+}
+
+// With returns the gauge for the label values
+func (v *GaugeVec) With(values ...string) *Gauge {
+	return v.f.child(values, func() any { return &Gauge{} }).(*Gauge)
+}
+
+// WithFunc returns a gauge for the label values that reports fn when scraped,
+// fn is only set when the gauge is created
+func (v *GaugeVec) WithFunc(fn func() float64, values ...string) *Gauge {
+	return v.f.child(values, func() any { return &Gauge{fn: fn} }).(*Gauge)
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	f       *family
+	buckets []float64
+}
+
+// NewHistogramVec creates a HistogramVec with sorted bucket upper bounds
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{f: newFamily(name, help, "histogram", labels), buckets: buckets}
+}
+
+// With returns the histogram for the label values
+func (v *HistogramVec) With(values ...string) *Histogram {
+	return v.f.child(values, func() any {
+		return &Histogram{buckets: v.buckets, counts: make([]uint64, len(v.buckets))}
+	}).(*Histogram)
+}
+
+// Collector is a metric vector that can be registered
+type Collector interface {
+	family() *family
+}
+
+func (v *CounterVec) family() *family   { return v.f }
+func (v *GaugeVec) family() *family     { return v.f }
+func (v *HistogramVec) family() *family { return v.f }
+
+// Registry is a set of metric families
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// MustRegister registers collectors, it panics on a duplicate name
+func (r *Registry) MustRegister(cs ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range cs {
+		f := c.family()
+		if _, ok := r.families[f.name]; ok {
+			panic("metrics: duplicate metric " + f.name)
+		}
+		r.families[f.name] = f
+	}
+}
+
+// WriteText writes every registered metric in the Prometheus text format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	families := make([]*family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	var b strings.Builder
+	for _, f := range families {
+		f.write(&b)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (f *family) write(b *strings.Builder) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.children) == 0 {
+		return
+	}
+
+	fmt.Fprintf(b, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", f.name, f.kind)
+
+	keys := make([]string, 0, len(f.children))
+	for k := range f.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		values := f.values[k]
+		switch m := f.children[k].(type) {
+		case *Counter:
+			writeSample(b, f.name, f.labels, values, "", "", m.Value())
+		case *Gauge:
+			writeSample(b, f.name, f.labels, values, "", "", m.Value())
+		case *Histogram:
+			m.mu.Lock()
+			var cumulative uint64
+			for i, upper := range m.buckets {
+				cumulative += m.counts[i]
+				writeSample(b, f.name+"_bucket", f.labels, values, "le", formatFloat(upper), float64(cumulative))
+			}
+			writeSample(b, f.name+"_bucket", f.labels, values, "le", "+Inf", float64(m.count))
+			writeSample(b, f.name+"_sum", f.labels, values, "", "", m.sum)
+			writeSample(b, f.name+"_count", f.labels, values, "", "", float64(m.count))
+			m.mu.Unlock()
+		}
+	}
+}
+
+// writeSample writes one sample line, extra is an additional label such as le
+func writeSample(b *strings.Builder, name string, labels, values []string, extra, extraValue string, v float64) {
+	b.WriteString(name)
+	if len(labels) > 0 || extra != "" {
+		b.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, `%s="%s"`, l, escapeLabel(values[i]))
+		}
+		if extra != "" {
+			if len(labels) > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(b, `%s="%s"`, extra, extraValue)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(v))
+	b.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	counter := NewCounterVec("test_requests_total", "Requests by path.", "path")
+	gauge := NewGaugeVec("test_temperature", "Temperature with a \\ and a\nnewline.")
+	fn := NewGaugeVec("test_up", "Computed when scraped.", "instance", "zone")
+	hist := NewHistogramVec("test_latency_seconds", "Request latency.", []float64{0.1, 0.5, 1}, "endpoint")
+	empty := NewCounterVec("test_empty_total", "Never used.", "instance")
+	r.MustRegister(counter, gauge, fn, hist, empty)
+
+	counter.With("/b").Inc()
+	counter.With("/a").Add(2.5)
+	counter.With(`quote"back\slash` + "\nline").Inc()
+	gauge.With().Set(-1.5)
+	fn.WithFunc(func() float64 { return 1 }, "dorm", "east")
+	fn.WithFunc(func() float64 { return 2 }, "dorm", "east") // The first function is kept
+	for _, v := range []float64{0.05, 0.1, 0.3, 0.7, 2} {
+		hist.With("auth").Observe(v)
+	}
+	hist.With("keep")
+
+	want := `# HELP test_latency_seconds Request latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{endpoint="auth",le="0.1"} 2
+test_latency_seconds_bucket{endpoint="auth",le="0.5"} 3
+test_latency_seconds_bucket{endpoint="auth",le="1"} 4
+test_latency_seconds_bucket{endpoint="auth",le="+Inf"} 5
+test_latency_seconds_sum{endpoint="auth"} 3.15
+test_latency_seconds_count{endpoint="auth"} 5
+test_latency_seconds_bucket{endpoint="keep",le="0.1"} 0
+test_latency_seconds_bucket{endpoint="keep",le="0.5"} 0
+test_latency_seconds_bucket{endpoint="keep",le="1"} 0
+test_latency_seconds_bucket{endpoint="keep",le="+Inf"} 0
+test_latency_seconds_sum{endpoint="keep"} 0
+test_latency_seconds_count{endpoint="keep"} 0
+# HELP test_requests_total Requests by path.
+# TYPE test_requests_total counter
+test_requests_total{path="/a"} 2.5
+test_requests_total{path="/b"} 1
+test_requests_total{path="quote\"back\\slash\nline"} 1
+# HELP test_temperature Temperature with a \\ and a\nnewline.
+# TYPE test_temperature gauge
+test_temperature -1.5
+# HELP test_up Computed when scraped.
+# TYPE test_up gauge
+test_up{instance="dorm",zone="east"} 1
+`
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != want {
+		t.Errorf("WriteText:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := map[float64]string{
+		0:       "0",
+		1:       "1",
+		0.005:   "0.005",
+		1e21:    "1e+21",
+		-2.5:    "-2.5",
+		1 / 3.0: "0.3333333333333333",
+	}
+	for v, want := range tests {
+		if got := formatFloat(v); got != want {
+			t.Errorf("formatFloat(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestMustRegisterDuplicate(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(NewCounterVec("test_total", "First."))
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a duplicate name did not panic")
+		}
+	}()
+	r.MustRegister(NewGaugeVec("test_total", "Second."))
+}
+
+func TestLabelCount(t *testing.T) {
+	v := NewCounterVec("test_total", "Labelled.", "a", "b")
+	defer func() {
+		if recover() == nil {
+			t.Error("a wrong label count did not panic")
+		}
+	}()
+	v.With("only one")
+}
@@ -1,8 +1,24 @@
 package models
 
+import (
+	"time"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/backoff"
+)
+
 // Options holds the user authentication options
 type Options struct {
 	LoginUser     string
 	LoginPassword string
 	SmsCode       string
+	CodeCooldown  time.Duration // Minimum time between SMS code requests, zero uses the default
+
+	// Client loop timing, zero values use the defaults
+	ProbeInterval time.Duration // Delay between connectivity probes while online
+
+	// Growth of the delay between consecutive failed probes, session requests and logins
+	Backoff backoff.Policy
+
+	// Consecutive failed heartbeats before logging in again, zero uses the default
+	HeartbeatFailures int
 }
@@ -5,10 +5,14 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"net/http"
+	neturl "net/url"
+	"strings"
 	"time"
 
 	"github.com/Rsplwe/ESurfingDialer/internal/constants"
@@ -21,28 +25,42 @@ type NetResult struct {
 	Error error
 }
 
+// logger returns the network component logger of st
+func logger(st *states.State) *slog.Logger {
+	return st.Logger().With("component", "network")
+}
+
+// dialContext returns a dial function bound to the interface of st when it has one
+func dialContext(st *states.State) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialer := &net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}
+		
+		// If interface is specified, bind to it
+		if iface := st.Interface(); iface != "" {
+			localAddr, err := getInterfaceAddr(iface)
+			if err != nil {
+				logger(st).Warn("Getting interface address failed", "interface", iface, "error", err)
+			} else if strings.HasPrefix(network, "udp") {
+				dialer.LocalAddr = &net.UDPAddr{IP: localAddr}
+			} else {
+				dialer.LocalAddr = &net.TCPAddr{IP: localAddr}
+			}
+		}
+		
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
+
 // CreateHTTPClient creates an HTTP client with custom redirect handling
-// If states.Interface is set, the client will bind to that network interface
-func CreateHTTPClient() *http.Client {
+// If st has an interface set, the client will bind to that network interface
+func CreateHTTPClient(st *states.State) *http.Client {
 	transport := &http.Transport{
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			dialer := &net.Dialer{
-				Timeout:   10 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}
-			
-			// If interface is specified, bind to it
-			if states.Interface != "" {
-				localAddr, err := getInterfaceAddr(states.Interface)
-				if err != nil {
-					fmt.Printf("Warning: Failed to get interface %s address: %v\n", states.Interface, err)
-				} else {
-					dialer.LocalAddr = &net.TCPAddr{IP: localAddr}
-				}
-			}
-			
-			return dialer.DialContext(ctx, network, addr)
-		},
+		DialContext:         dialContext(st),
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     90 * time.Second,
 	}
 	
 	return &http.Client{
@@ -55,6 +73,19 @@ func CreateHTTPClient() *http.Client {
 	}
 }
 
+// BindTransport returns rt dialing through the interface of st. A *http.Transport
+// without a dialer of its own is copied with the bound dialer set, any other
+// round tripper is returned as is and must bind the interface itself.
+func BindTransport(rt http.RoundTripper, st *states.State) http.RoundTripper {
+	t, ok := rt.(*http.Transport)
+	if !ok || t.DialContext != nil || t.Dial != nil || t.DialTLSContext != nil || t.DialTLS != nil {
+		return rt
+	}
+	t = t.Clone()
+	t.DialContext = dialContext(st)
+	return t
+}
+
 // getInterfaceAddr returns the first IPv4 address of the specified network interface
 func getInterfaceAddr(ifaceName string) (net.IP, error) {
 	iface, err := net.InterfaceByName(ifaceName)
@@ -86,12 +117,12 @@ func getInterfaceAddr(ifaceName string) (net.IP, error) {
 }
 
 // Post sends a POST request with encrypted data
-func Post(client *http.Client, url string, data string, extraHeaders map[string]string) *NetResult {
+func Post(ctx context.Context, client *http.Client, st *states.State, url string, data string, extraHeaders map[string]string) *NetResult {
 	body := bytes.NewBufferString(data)
 	
-	req, err := http.NewRequest("POST", url, body)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
-		return &NetResult{Error: err}
+		return &NetResult{Error: withoutURL(err)}
 	}
 
 	// Set headers
@@ -99,8 +130,8 @@ func Post(client *http.Client, url string, data string, extraHeaders map[string]
 	req.Header.Set("Accept", constants.RequestAccept)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("CDC-Checksum", MD5Hash(data))
-	req.Header.Set("Client-ID", states.ClientID)
-	req.Header.Set("Algo-ID", states.AlgoID)
+	req.Header.Set("Client-ID", st.ClientID())
+	req.Header.Set("Algo-ID", st.AlgoID())
 
 	// Add extra headers
 	for k, v := range extraHeaders {
@@ -108,19 +139,19 @@ func Post(client *http.Client, url string, data string, extraHeaders map[string]
 	}
 
 	// Add CDC headers if available
-	if states.SchoolID != "" {
-		req.Header.Set("CDC-SchoolId", states.SchoolID)
+	if schoolID := st.SchoolID(); schoolID != "" {
+		req.Header.Set("CDC-SchoolId", schoolID)
 	}
-	if states.Domain != "" {
-		req.Header.Set("CDC-Domain", states.Domain)
+	if domain := st.Domain(); domain != "" {
+		req.Header.Set("CDC-Domain", domain)
 	}
-	if states.Area != "" {
-		req.Header.Set("CDC-Area", states.Area)
+	if area := st.Area(); area != "" {
+		req.Header.Set("CDC-Area", area)
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return &NetResult{Error: err}
+		return &NetResult{Error: withoutURL(err)}
 	}
 	defer resp.Body.Close()
 
@@ -132,6 +163,16 @@ func Post(client *http.Client, url string, data string, extraHeaders map[string]
 	return &NetResult{Data: respData, Error: nil}
 }
 
+// withoutURL drops the request URL from err, portal URLs carry session secrets
+// such as the keep and term URLs and must not end up in logs
+func withoutURL(err error) error {
+	var uerr *neturl.Error
+	if errors.As(err, &uerr) {
+		return fmt.Errorf("%s request: %w", uerr.Op, uerr.Err)
+	}
+	return err
+}
+
 // MD5Hash calculates MD5 hash of a string
 func MD5Hash(text string) string {
 	hash := md5.Sum([]byte(text))
@@ -139,19 +180,19 @@ func MD5Hash(text string) string {
 }
 
 // HandleRedirects manually handles HTTP redirects and extracts headers
-func HandleRedirects(client *http.Client, initialURL string) (*http.Response, error) {
+func HandleRedirects(ctx context.Context, client *http.Client, st *states.State, initialURL string) (*http.Response, error) {
 	maxRedirects := 5
 	currentURL := initialURL
 	
 	for i := 0; i < maxRedirects; i++ {
-		req, err := http.NewRequest("GET", currentURL, nil)
+		req, err := http.NewRequestWithContext(ctx, "GET", currentURL, nil)
 		if err != nil {
 			return nil, err
 		}
 		
 		req.Header.Set("User-Agent", constants.UserAgent)
 		req.Header.Set("Accept", constants.RequestAccept)
-		req.Header.Set("Client-ID", states.ClientID)
+		req.Header.Set("Client-ID", st.ClientID())
 		
 		resp, err := client.Do(req)
 		if err != nil {
@@ -160,16 +201,16 @@ func HandleRedirects(client *http.Client, initialURL string) (*http.Response, er
 		
 		// Check for redirect headers
 		if area := resp.Header.Get("area"); area != "" {
-			states.Area = area
-			fmt.Printf("Add Header -> CDC-Area: %s\n", states.Area)
+			st.SetArea(area)
+			logger(st).Debug("Added header", "name", "CDC-Area", "value", area)
 		}
 		if schoolID := resp.Header.Get("schoolid"); schoolID != "" {
-			states.SchoolID = schoolID
-			fmt.Printf("Add Header -> CDC-SchoolId: %s\n", states.SchoolID)
+			st.SetSchoolID(schoolID)
+			logger(st).Debug("Added header", "name", "CDC-SchoolId", "value", schoolID)
 		}
 		if domain := resp.Header.Get("domain"); domain != "" {
-			states.Domain = domain
-			fmt.Printf("Add Header -> CDC-Domain: %s\n", states.Domain)
+			st.SetDomain(domain)
+			logger(st).Debug("Added header", "name", "CDC-Domain", "value", domain)
 		}
 		
 		// If not a redirect, return the response
@@ -185,7 +226,7 @@ func HandleRedirects(client *http.Client, initialURL string) (*http.Response, er
 		
 		resp.Body.Close()
 		currentURL = location
-		fmt.Printf("Redirect #%d to: %s\n", i+1, currentURL)
+		logger(st).Debug("Following redirect", "count", i+1, "host", urlHost(currentURL))
 	}
 	
 	return nil, fmt.Errorf("too many redirects")
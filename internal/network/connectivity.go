@@ -1,19 +1,14 @@
 package network
 
 import (
-	"crypto/md5"
-	"encoding/hex"
-	"encoding/json"
+	"context"
 	"encoding/xml"
-	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
 	"github.com/Rsplwe/ESurfingDialer/internal/constants"
-	"github.com/Rsplwe/ESurfingDialer/internal/models"
 	"github.com/Rsplwe/ESurfingDialer/internal/states"
 	"github.com/Rsplwe/ESurfingDialer/internal/utils"
 )
@@ -44,146 +39,99 @@ const (
 	RequestError
 )
 
-// DetectConfig detects network configuration and authorization requirements
-func DetectConfig() ConnectivityStatus {
-	client := CreateHTTPClient()
-	
-	resp, err := HandleRedirects(client, constants.CaptiveURL)
-	if err != nil {
-		fmt.Printf("Request Error: %v\n", err)
-		return RequestError
+func (s ConnectivityStatus) String() string {
+	switch s {
+	case Success:
+		return "success"
+	case RequireAuthorization:
+		return "require_authorization"
+	case RequestError:
+		return "request_error"
+	default:
+		return "unknown"
 	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
-		fmt.Printf("Request Code: %d\n", resp.StatusCode)
-		return RequestError
-	}
-	
-	content, err := io.ReadAll(resp.Body)
+}
+
+// DetectConfig checks the probe targets with client and, behind a captive
+// portal, stores its configuration in st
+func DetectConfig(ctx context.Context, st *states.State, client *http.Client, probes ProbeConfig) ConnectivityStatus {
+	res, err := probes.probe(ctx, client, st)
 	if err != nil {
-		fmt.Printf("Error reading response: %v\n", err)
+		logger(st).Warn("Connectivity check failed", "error", err)
 		return RequestError
 	}
-	
-	portalConfig := utils.ExtractBetweenTags(string(content), constants.PortalStartTag, constants.PortalEndTag)
+	portalConfig := res.portal
 	if portalConfig == "" {
 		return Success
 	}
 	
-	// Debug: Print the extracted portal config
-	fmt.Printf("Portal Config extracted: %s\n", portalConfig)
+	logger(st).Debug("Portal config extracted", "probe", res.probe.Name, "length", len(portalConfig))
 	
 	// Parse XML configuration (using lenient parser that handles unescaped ampersands)
 	var config PortalConfig
 	err = utils.UnmarshalXML([]byte(portalConfig), &config)
 	if err != nil {
-		fmt.Printf("Error parsing XML: %v\n", err)
-		fmt.Printf("XML content: %s\n", portalConfig)
+		logger(st).Error("Parsing portal config failed", "error", err, "length", len(portalConfig))
 		return RequestError
 	}
 	
-	states.AuthURL = strings.TrimSpace(config.AuthURL)
-	states.TicketURL = strings.TrimSpace(config.TicketURL)
+	authURL := strings.TrimSpace(config.AuthURL)
+	ticketURL := strings.TrimSpace(config.TicketURL)
 	
 	// Decode HTML entities in URLs (e.g., &amp; to &)
-	states.AuthURL = strings.ReplaceAll(states.AuthURL, "&amp;", "&")
-	states.TicketURL = strings.ReplaceAll(states.TicketURL, "&amp;", "&")
+	authURL = strings.ReplaceAll(authURL, "&amp;", "&")
+	ticketURL = strings.ReplaceAll(ticketURL, "&amp;", "&")
+	st.SetAuthURL(authURL)
+	st.SetTicketURL(ticketURL)
 	
-	fmt.Printf("Parsed auth-url: %s\n", states.AuthURL)
-	fmt.Printf("Parsed ticket-url: %s\n", states.TicketURL)
+	logger(st).Debug("Portal config parsed", "auth_host", urlHost(authURL), "ticket_host", urlHost(ticketURL))
 	
 	// Parse extra function URLs
 	for _, item := range config.FuncCfg.Items {
 		if item.Enable == "1" && item.URL != "" {
-			states.ExtraCfgURL[item.XMLName.Local] = item.URL
-			fmt.Printf("Added extra config: %s -> %s\n", item.XMLName.Local, item.URL)
+			st.SetExtraCfgURL(item.XMLName.Local, item.URL)
+			logger(st).Debug("Added extra config", "name", item.XMLName.Local, "url", item.URL)
 		}
 	}
 	
-	if states.AuthURL == "" || states.TicketURL == "" {
-		fmt.Printf("Missing auth-url or ticket-url. AuthURL='%s', TicketURL='%s'\n", states.AuthURL, states.TicketURL)
+	if authURL == "" || ticketURL == "" {
+		logger(st).Error("Portal config is missing auth-url or ticket-url", "auth_url", authURL != "", "ticket_url", ticketURL != "")
 		return RequestError
 	}
 	
 	// Parse URL parameters
-	ticketURL, err := url.Parse(states.TicketURL)
+	parsedTicketURL, err := url.Parse(ticketURL)
 	if err != nil {
-		fmt.Printf("Error parsing ticket URL: %v\n", err)
+		logger(st).Error("Parsing ticket URL failed", "error", err)
 		return RequestError
 	}
 	
-	params := ticketURL.Query()
-	states.UserIP = params.Get("wlanuserip")
-	states.ACIP = params.Get("wlanacip")
+	params := parsedTicketURL.Query()
+	userIP := params.Get("wlanuserip")
+	acIP := params.Get("wlanacip")
+	st.SetUserIP(userIP)
+	st.SetACIP(acIP)
 	
-	if states.UserIP == "" || states.ACIP == "" {
-		fmt.Println("Missing userIp or acIp")
+	if userIP == "" || acIP == "" {
+		logger(st).Error("Ticket URL is missing wlanuserip or wlanacip")
 		return RequestError
 	}
 	
 	return RequireAuthorization
 }
 
-// CheckVerifyCodeStatus checks if SMS verification is required
-func CheckVerifyCodeStatus(username string) bool {
-	return requestVerifyCode(username, "QueryVerificateCodeStatus", "11062000")
-}
-
-// GetVerifyCode requests SMS verification code
-func GetVerifyCode(username string) bool {
-	return requestVerifyCode(username, "QueryAuthCode", "0")
-}
-
-func requestVerifyCode(username, reqType, successCode string) bool {
-	url, exists := states.ExtraCfgURL[reqType]
-	if !exists || url == "" {
-		return false
-	}
-	
-	timestamp := fmt.Sprintf("%d", currentTimeMillis())
-	authenticator := md5.Sum([]byte(states.SchoolID + timestamp + constants.AuthKey))
-	
-	reqData := models.RequireVerificate{
-		SchoolID:      states.SchoolID,
-		Username:      username,
-		Timestamp:     timestamp,
-		Authenticator: strings.ToUpper(hex.EncodeToString(authenticator[:])),
-	}
-	
-	jsonData, err := json.Marshal(reqData)
-	if err != nil {
-		return false
-	}
-	
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonData)))
-	if err != nil {
-		return false
-	}
-	
-	req.Header.Set("User-Agent", constants.UserAgent)
-	req.Header.Set("Accept", "okhttp/3.4.1")
-	req.Header.Set("Content-Type", "application/json")
-	
-	client := CreateHTTPClient()
-	resp, err := client.Do(req)
+// urlHost returns the host of a URL for logging, the query carries session parameters
+func urlHost(u string) string {
+	parsed, err := url.Parse(u)
 	if err != nil {
-		fmt.Printf("Error requesting verify code: %v\n", err)
-		return false
-	}
-	defer resp.Body.Close()
-	
-	if resp.StatusCode != http.StatusOK {
-		return false
-	}
-	
-	var result models.ResponseRequireVerificate
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		fmt.Printf("Error decoding response: %v\n", err)
-		return false
+		return "invalid"
 	}
-	
-	return result.ResCode == successCode
+	return parsed.Host
+}
+
+// extractPortalConfig returns the portal config embedded in a page, empty when there is none
+func extractPortalConfig(page string) string {
+	return utils.ExtractBetweenTags(page, constants.PortalStartTag, constants.PortalEndTag)
 }
 
 func currentTimeMillis() int64 {
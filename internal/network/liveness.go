@@ -0,0 +1,136 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/states"
+)
+
+// Liveness check modes
+const (
+	LivenessOff  = ""     // Every check is a full portal detection
+	LivenessHTTP = "http" // Request the first probe target over a kept-alive connection
+	LivenessTCP  = "tcp"  // Connect to a TCP address
+	LivenessDNS  = "dns"  // Resolve a host name
+)
+
+// Default liveness targets
+const (
+	DefaultLivenessTCP = "223.5.5.5:53"
+	DefaultLivenessDNS = "www.baidu.com"
+)
+
+// ErrCaptive is returned by a liveness check answered by a captive portal
+var ErrCaptive = errors.New("captive portal detected")
+
+// Liveness is a cheap check that the network is still online after a login.
+// TCP and DNS checks cannot see a portal that still lets them through, the
+// failed heartbeats then trigger a new login.
+type Liveness struct {
+	Mode     string
+	Target   string        // Probe spec for http, host:port for tcp, host name for dns, empty uses the default
+	Interval time.Duration // Delay between checks, zero keeps the probe interval
+}
+
+// ParseLiveness validates mode and target
+func ParseLiveness(mode, target string) (Liveness, error) {
+	l := Liveness{Mode: strings.ToLower(strings.TrimSpace(mode)), Target: strings.TrimSpace(target)}
+	switch l.Mode {
+	case "off", "none":
+		l.Mode = LivenessOff
+	case LivenessOff, LivenessDNS:
+	case LivenessHTTP:
+		if l.Target != "" {
+			if _, err := ParseProbe(l.Target); err != nil {
+				return Liveness{}, fmt.Errorf("liveness target: %w", err)
+			}
+		}
+	case LivenessTCP:
+		if l.Target != "" {
+			if _, _, err := net.SplitHostPort(l.Target); err != nil {
+				return Liveness{}, fmt.Errorf("liveness target %q: %w", l.Target, err)
+			}
+		}
+	default:
+		return Liveness{}, fmt.Errorf("unknown liveness mode %q, use http, tcp or dns", mode)
+	}
+	return l, nil
+}
+
+// Enabled reports whether checks other than a full portal detection are made
+func (l Liveness) Enabled() bool {
+	return l.Mode != LivenessOff
+}
+
+// Check reports whether the network is still online, nil means it is. The http
+// mode uses client, which should be shared between checks to reuse its connection.
+func (l Liveness) Check(ctx context.Context, st *states.State, client *http.Client, probes ProbeConfig) error {
+	switch l.Mode {
+	case LivenessHTTP:
+		return l.checkHTTP(ctx, st, client, probes)
+	case LivenessTCP:
+		return l.checkTCP(ctx, st)
+	case LivenessDNS:
+		return l.checkDNS(ctx, st)
+	default:
+		return errors.New("liveness checks are disabled")
+	}
+}
+
+func (l Liveness) checkHTTP(ctx context.Context, st *states.State, client *http.Client, probes ProbeConfig) error {
+	var p Probe
+	if l.Target != "" {
+		var err error
+		if p, err = ParseProbe(l.Target); err != nil {
+			return err
+		}
+	} else if len(probes.Probes) > 0 {
+		p = probes.Probes[0]
+	} else {
+		p = DefaultProbes[0]
+	}
+
+	res, err := p.run(ctx, client, st)
+	if err != nil {
+		return err
+	}
+	if res.portal != "" {
+		return ErrCaptive
+	}
+	return nil
+}
+
+func (l Liveness) checkTCP(ctx context.Context, st *states.State) error {
+	addr := l.Target
+	if addr == "" {
+		addr = DefaultLivenessTCP
+	}
+	conn, err := dialContext(st)(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (l Liveness) checkDNS(ctx context.Context, st *states.State) error {
+	host := l.Target
+	if host == "" {
+		host = DefaultLivenessDNS
+	}
+	// The pure Go resolver dials through the bound interface
+	resolver := &net.Resolver{PreferGo: true, Dial: dialContext(st)}
+	addrs, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no address for %s", host)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseLiveness(t *testing.T) {
+	tests := []struct {
+		mode, target string
+		want         string
+		wantErr      bool
+	}{
+		{"", "", LivenessOff, false},
+		{"off", "", LivenessOff, false},
+		{" None ", "", LivenessOff, false},
+		{"HTTP", "", LivenessHTTP, false},
+		{"http", "apple", LivenessHTTP, false},
+		{"http", "nosuchpreset", "", true},
+		{"tcp", "", LivenessTCP, false},
+		{"tcp", "223.5.5.5:53", LivenessTCP, false},
+		{"tcp", "223.5.5.5", "", true},
+		{"dns", "www.baidu.com", LivenessDNS, false},
+		{"icmp", "", "", true},
+	}
+	for _, tt := range tests {
+		l, err := ParseLiveness(tt.mode, tt.target)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLiveness(%q, %q) error = %v", tt.mode, tt.target, err)
+			continue
+		}
+		if err == nil && l.Mode != tt.want {
+			t.Errorf("ParseLiveness(%q, %q) mode = %q, want %q", tt.mode, tt.target, l.Mode, tt.want)
+		}
+	}
+	if (Liveness{}).Enabled() {
+		t.Error("the zero Liveness is enabled")
+	}
+}
+
+func TestLivenessTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	l := Liveness{Mode: LivenessTCP, Target: addr}
+	st := newProbeState()
+
+	if err := l.Check(context.Background(), st, nil, ProbeConfig{}); err != nil {
+		t.Errorf("Check with the listener up: %v", err)
+	}
+	ln.Close()
+	if err := l.Check(context.Background(), st, nil, ProbeConfig{}); err == nil {
+		t.Error("Check succeeded with the listener closed")
+	}
+}
+
+func TestLivenessHTTP(t *testing.T) {
+	var captive atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/blocked":
+			http.Error(w, "blocked", http.StatusForbidden)
+		case captive.Load():
+			fmt.Fprint(w, portalPage("http://portal.test"))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+	st := newProbeState()
+	client := CreateHTTPClient(st)
+	probes := ProbeConfig{Probes: []Probe{{Name: "test", URL: srv.URL, Status: http.StatusNoContent}}}
+
+	// Without a target the first probe is checked
+	l := Liveness{Mode: LivenessHTTP}
+	if err := l.Check(context.Background(), st, client, probes); err != nil {
+		t.Errorf("Check online: %v", err)
+	}
+	if err := (Liveness{Mode: LivenessHTTP, Target: srv.URL + "/blocked"}).Check(context.Background(), st, client, probes); !errors.Is(err, errProbeMismatch) {
+		t.Errorf("Check of the target = %v, want errProbeMismatch", err)
+	}
+	captive.Store(true)
+	if err := l.Check(context.Background(), st, client, probes); !errors.Is(err, ErrCaptive) {
+		t.Errorf("Check behind the portal = %v, want ErrCaptive", err)
+	}
+	if st.AuthURL() != "" {
+		t.Error("a liveness check stored the portal config")
+	}
+}
+
+func TestLivenessDNS(t *testing.T) {
+	// localhost resolves from the hosts file without a network
+	st := newProbeState()
+	if err := (Liveness{Mode: LivenessDNS, Target: "localhost"}).Check(context.Background(), st, nil, ProbeConfig{}); err != nil {
+		t.Errorf("Check localhost: %v", err)
+	}
+}
+
+func TestLivenessDisabled(t *testing.T) {
+	if err := (Liveness{}).Check(context.Background(), newProbeState(), nil, ProbeConfig{}); err == nil {
+		t.Error("Check succeeded while disabled")
+	}
+}
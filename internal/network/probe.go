@@ -0,0 +1,205 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/constants"
+	"github.com/Rsplwe/ESurfingDialer/internal/states"
+)
+
+// maxProbeBody bounds how much of a probe response is read, a portal page fits easily
+const maxProbeBody = 256 << 10
+
+// Probe is a connectivity check target. Without a captive portal the response has
+// the expected status and, when Body is set, contains Body.
+type Probe struct {
+	Name   string
+	URL    string
+	Status int    // Expected status, zero accepts 200 and 204
+	Body   string // Expected body substring, empty skips the body check
+}
+
+// Probes are the built-in probe targets by preset name
+var Probes = map[string]Probe{
+	"xiaomi":    {Name: "xiaomi", URL: constants.CaptiveURL, Status: http.StatusNoContent},
+	"google":    {Name: "google", URL: "http://www.gstatic.com/generate_204", Status: http.StatusNoContent},
+	"apple":     {Name: "apple", URL: "http://captive.apple.com/hotspot-detect.html", Status: http.StatusOK, Body: "Success"},
+	"microsoft": {Name: "microsoft", URL: "http://www.msftconnecttest.com/connecttest.txt", Status: http.StatusOK, Body: "Microsoft Connect Test"},
+	"huawei":    {Name: "huawei", URL: "http://connectivitycheck.platform.hicloud.com/generate_204", Status: http.StatusNoContent},
+}
+
+// DefaultProbes is used when no probe is configured
+var DefaultProbes = []Probe{Probes["xiaomi"]}
+
+// ProbePresets returns the preset names sorted
+func ProbePresets() []string {
+	names := make([]string, 0, len(Probes))
+	for name := range Probes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseProbe creates a probe from a spec:
+//
+//	NAME                  a preset such as google or apple
+//	URL                   a custom target answering 200 or 204
+//	URL|STATUS            a custom target answering STATUS
+//	URL|STATUS|BODY       a custom target answering STATUS with BODY in the response
+func ParseProbe(spec string) (Probe, error) {
+	spec = strings.TrimSpace(spec)
+	if p, ok := Probes[strings.ToLower(spec)]; ok {
+		return p, nil
+	}
+	if !strings.HasPrefix(spec, "http://") && !strings.HasPrefix(spec, "https://") {
+		return Probe{}, fmt.Errorf("unknown probe %q, use a URL or one of: %s", spec, strings.Join(ProbePresets(), ", "))
+	}
+
+	parts := strings.SplitN(spec, "|", 3)
+	p := Probe{Name: "custom", URL: parts[0]}
+	if len(parts) > 1 && parts[1] != "" {
+		status, err := strconv.Atoi(parts[1])
+		if err != nil || status < 100 || status > 599 {
+			return Probe{}, fmt.Errorf("probe %q: invalid status %q", spec, parts[1])
+		}
+		p.Status = status
+	}
+	if len(parts) > 2 {
+		p.Body = parts[2]
+	}
+	return p, nil
+}
+
+// ParseProbes parses a list of probe specs, an empty list gives DefaultProbes
+func ParseProbes(specs []string) ([]Probe, error) {
+	if len(specs) == 0 {
+		return DefaultProbes, nil
+	}
+	probes := make([]Probe, 0, len(specs))
+	for _, spec := range specs {
+		p, err := ParseProbe(spec)
+		if err != nil {
+			return nil, err
+		}
+		probes = append(probes, p)
+	}
+	return probes, nil
+}
+
+// ProbeConfig selects the targets DetectConfig checks
+type ProbeConfig struct {
+	Probes   []Probe // Tried in order, DefaultProbes when empty
+	Parallel bool    // Race every probe and take the first conclusive answer
+}
+
+// errProbeMismatch is a response that is neither the expected one nor a portal page
+var errProbeMismatch = errors.New("unexpected probe response")
+
+// probeResult is the conclusive answer of one probe
+type probeResult struct {
+	probe  Probe
+	portal string // Portal config of a captive portal, empty when online
+}
+
+// match reports whether a response is the one expected without a captive portal
+func (p Probe) match(status int, body string) bool {
+	if p.Status == 0 {
+		if status != http.StatusOK && status != http.StatusNoContent {
+			return false
+		}
+	} else if status != p.Status {
+		return false
+	}
+	return p.Body == "" || strings.Contains(body, p.Body)
+}
+
+// run checks one probe, a page carrying the portal config is conclusive even
+// when its status differs from the expected one
+func (p Probe) run(ctx context.Context, client *http.Client, st *states.State) (probeResult, error) {
+	resp, err := HandleRedirects(ctx, client, st, p.URL)
+	if err != nil {
+		return probeResult{}, err
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	if err != nil {
+		return probeResult{}, fmt.Errorf("reading response: %w", err)
+	}
+	body := string(content)
+
+	if portal := extractPortalConfig(body); portal != "" {
+		return probeResult{probe: p, portal: portal}, nil
+	}
+	if !p.match(resp.StatusCode, body) {
+		return probeResult{}, fmt.Errorf("%w: status %d", errProbeMismatch, resp.StatusCode)
+	}
+	return probeResult{probe: p}, nil
+}
+
+// probe runs the configured probes until one answers conclusively
+func (cfg ProbeConfig) probe(ctx context.Context, client *http.Client, st *states.State) (probeResult, error) {
+	probes := cfg.Probes
+	if len(probes) == 0 {
+		probes = DefaultProbes
+	}
+	if cfg.Parallel && len(probes) > 1 {
+		return raceProbes(ctx, client, st, probes)
+	}
+
+	var errs []error
+	for _, p := range probes {
+		res, err := p.run(ctx, client, st)
+		if err == nil {
+			return res, nil
+		}
+		if ctx.Err() != nil {
+			return probeResult{}, ctx.Err()
+		}
+		logger(st).Debug("Probe failed", "probe", p.Name, "url", p.URL, "error", err)
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name, err))
+	}
+	return probeResult{}, errors.Join(errs...)
+}
+
+// raceProbes runs every probe at once and returns the first conclusive answer
+func raceProbes(ctx context.Context, client *http.Client, st *states.State, probes []Probe) (probeResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type answer struct {
+		res probeResult
+		err error
+	}
+	answers := make(chan answer, len(probes))
+	for _, p := range probes {
+		go func(p Probe) {
+			res, err := p.run(ctx, client, st)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", p.Name, err)
+			}
+			answers <- answer{res, err}
+		}(p)
+	}
+
+	var errs []error
+	for range probes {
+		a := <-answers
+		if a.err == nil {
+			return a.res, nil
+		}
+		errs = append(errs, a.err)
+	}
+	if ctx.Err() != nil {
+		return probeResult{}, ctx.Err()
+	}
+	return probeResult{}, errors.Join(errs...)
+}
@@ -0,0 +1,161 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/constants"
+	"github.com/Rsplwe/ESurfingDialer/internal/states"
+)
+
+func TestParseProbe(t *testing.T) {
+	for _, name := range ProbePresets() {
+		p, err := ParseProbe(name)
+		if err != nil {
+			t.Errorf("ParseProbe(%q): %v", name, err)
+			continue
+		}
+		if p != Probes[name] || p.URL == "" {
+			t.Errorf("ParseProbe(%q) = %+v", name, p)
+		}
+	}
+
+	tests := []struct {
+		spec string
+		want Probe
+	}{
+		{" Google ", Probes["google"]},
+		{"http://example.com/ok", Probe{Name: "custom", URL: "http://example.com/ok"}},
+		{"https://example.com/ok|204", Probe{Name: "custom", URL: "https://example.com/ok", Status: 204}},
+		{"http://example.com/ok|200|all|good", Probe{Name: "custom", URL: "http://example.com/ok", Status: 200, Body: "all|good"}},
+		{"http://example.com/ok||Success", Probe{Name: "custom", URL: "http://example.com/ok", Body: "Success"}},
+	}
+	for _, tt := range tests {
+		if got, err := ParseProbe(tt.spec); err != nil || got != tt.want {
+			t.Errorf("ParseProbe(%q) = %+v, %v, want %+v", tt.spec, got, err, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "nosuchpreset", "example.com/ok", "http://example.com|abc", "http://example.com|99", "http://example.com|600"} {
+		if _, err := ParseProbe(spec); err == nil {
+			t.Errorf("ParseProbe(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestParseProbes(t *testing.T) {
+	if got, err := ParseProbes(nil); err != nil || len(got) != 1 || got[0] != DefaultProbes[0] {
+		t.Errorf("ParseProbes(nil) = %+v, %v, want the defaults", got, err)
+	}
+	got, err := ParseProbes([]string{"apple", "http://example.com"})
+	if err != nil || len(got) != 2 || got[0].Name != "apple" || got[1].Name != "custom" {
+		t.Errorf("ParseProbes = %+v, %v", got, err)
+	}
+	if _, err := ParseProbes([]string{"apple", "nosuchpreset"}); err == nil {
+		t.Error("ParseProbes with an unknown preset succeeded")
+	}
+}
+
+// portalPage is a captive portal page carrying its config
+func portalPage(base string) string {
+	return fmt.Sprintf("<html>%s<config><auth-url>%s/auth</auth-url><ticket-url>%s/ticket?wlanuserip=10.0.0.2&amp;wlanacip=10.0.0.1</ticket-url></config>%s</html>",
+		constants.PortalStartTag, base, base, constants.PortalEndTag)
+}
+
+func newProbeState() *states.State {
+	st := states.New()
+	st.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return st
+}
+
+func TestDetectConfig(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/204", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/success", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html>Success</html>")
+	})
+	var srv *httptest.Server
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, srv.URL+"/portal", http.StatusFound)
+	})
+	mux.HandleFunc("/portal", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("schoolid", "1234")
+		fmt.Fprint(w, portalPage("http://portal.test"))
+	})
+	mux.HandleFunc("/blocked", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "blocked", http.StatusForbidden)
+	})
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	probe := func(path string, status int, body string) Probe {
+		return Probe{Name: path, URL: srv.URL + path, Status: status, Body: body}
+	}
+	tests := []struct {
+		name     string
+		probes   []Probe
+		parallel bool
+		want     ConnectivityStatus
+	}{
+		{"no content", []Probe{probe("/204", 204, "")}, false, Success},
+		{"any ok status", []Probe{probe("/204", 0, "")}, false, Success},
+		{"expected body", []Probe{probe("/success", 200, "Success")}, false, Success},
+		{"redirect to the portal", []Probe{probe("/redirect", 204, "")}, false, RequireAuthorization},
+		{"portal with another status", []Probe{probe("/portal", 204, "")}, false, RequireAuthorization},
+		{"wrong status", []Probe{probe("/success", 204, "")}, false, RequestError},
+		{"wrong body", []Probe{probe("/success", 200, "Microsoft")}, false, RequestError},
+		{"error status", []Probe{probe("/blocked", 0, "")}, false, RequestError},
+		{"unreachable", []Probe{{Name: "down", URL: down.URL}}, false, RequestError},
+		{"falls through to the next", []Probe{probe("/blocked", 0, ""), probe("/redirect", 204, "")}, false, RequireAuthorization},
+		{"first conclusive answer wins", []Probe{probe("/204", 204, ""), probe("/portal", 204, "")}, false, Success},
+		{"parallel", []Probe{{Name: "down", URL: down.URL}, probe("/blocked", 0, ""), probe("/redirect", 204, "")}, true, RequireAuthorization},
+		{"parallel all fail", []Probe{{Name: "down", URL: down.URL}, probe("/blocked", 0, "")}, true, RequestError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newProbeState()
+			got := DetectConfig(context.Background(), st, CreateHTTPClient(st), ProbeConfig{Probes: tt.probes, Parallel: tt.parallel})
+			if got != tt.want {
+				t.Fatalf("DetectConfig = %s, want %s", got, tt.want)
+			}
+			if got == RequireAuthorization {
+				if st.AuthURL() != "http://portal.test/auth" || st.UserIP() != "10.0.0.2" || st.ACIP() != "10.0.0.1" || st.SchoolID() != "1234" {
+					t.Errorf("portal config not stored: auth %q, user ip %q, ac ip %q, school %q", st.AuthURL(), st.UserIP(), st.ACIP(), st.SchoolID())
+				}
+			}
+		})
+	}
+}
+
+func TestProbeMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html>login here</html>")
+	}))
+	defer srv.Close()
+
+	st := newProbeState()
+	_, err := Probe{Name: "test", URL: srv.URL, Status: http.StatusNoContent}.run(context.Background(), CreateHTTPClient(st), st)
+	if !errors.Is(err, errProbeMismatch) {
+		t.Errorf("run = %v, want errProbeMismatch", err)
+	}
+}
+
+func TestProbeCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	st := newProbeState()
+	cfg := ProbeConfig{Probes: []Probe{{Name: "a", URL: "http://127.0.0.1:1"}, {Name: "b", URL: "http://127.0.0.1:1"}}}
+	if _, err := cfg.probe(ctx, CreateHTTPClient(st), st); !errors.Is(err, context.Canceled) {
+		t.Errorf("probe = %v, want context.Canceled", err)
+	}
+}
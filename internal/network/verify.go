@@ -0,0 +1,163 @@
+package network
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/constants"
+	"github.com/Rsplwe/ESurfingDialer/internal/models"
+	"github.com/Rsplwe/ESurfingDialer/internal/states"
+)
+
+// Portal functions of the SMS verification sub-protocol and their success codes
+const (
+	verifyStatusFunc = "QueryVerificateCodeStatus"
+	verifyStatusCode = "11062000" // Verification is required
+	verifyCodeFunc   = "QueryAuthCode"
+	verifyCodeSent   = "0"
+)
+
+// rateLimitHints match the server message of a rejected code request that
+// was sent too soon, the portal has no distinct rescode for it
+var rateLimitHints = []string{"频繁", "稍后", "frequent", "too many", "too often", "rate limit", "try again later"}
+
+// ErrVerifyUnsupported is returned when the portal did not enable a verification function
+var ErrVerifyUnsupported = errors.New("verification function not enabled by the portal")
+
+// VerifyStatus is the outcome of an SMS verification code request
+type VerifyStatus int
+
+const (
+	VerifyNotRequired VerifyStatus = iota
+	VerifyCodeSent
+	VerifyRateLimited
+	VerifyError
+)
+
+func (s VerifyStatus) String() string {
+	switch s {
+	case VerifyNotRequired:
+		return "not_required"
+	case VerifyCodeSent:
+		return "code_sent"
+	case VerifyRateLimited:
+		return "rate_limited"
+	default:
+		return "error"
+	}
+}
+
+func (s VerifyStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// VerifyResult is the parsed response of a verification request
+type VerifyResult struct {
+	Status  VerifyStatus `json:"status"`
+	Phone   string       `json:"phone,omitempty"`   // Masked phone number the code is sent to
+	Message string       `json:"message,omitempty"` // Server message
+	ResCode string       `json:"rescode,omitempty"`
+}
+
+// VerifyRequired asks the portal whether user must enter an SMS verification code
+func VerifyRequired(ctx context.Context, client *http.Client, st *states.State, username string) (bool, error) {
+	resp, err := requestVerify(ctx, client, st, username, verifyStatusFunc)
+	if errors.Is(err, ErrVerifyUnsupported) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return resp.ResCode == verifyStatusCode, nil
+}
+
+// RequestVerifyCode asks the portal to send an SMS verification code to user
+func RequestVerifyCode(ctx context.Context, client *http.Client, st *states.State, username string) (VerifyResult, error) {
+	resp, err := requestVerify(ctx, client, st, username, verifyCodeFunc)
+	if errors.Is(err, ErrVerifyUnsupported) {
+		return VerifyResult{Status: VerifyNotRequired}, nil
+	}
+	if err != nil {
+		return VerifyResult{Status: VerifyError, Message: err.Error()}, err
+	}
+
+	result := VerifyResult{
+		Phone:   strings.TrimSpace(resp.Phone),
+		Message: strings.TrimSpace(resp.ResInfo),
+		ResCode: strings.TrimSpace(resp.ResCode),
+	}
+	switch {
+	case result.ResCode == verifyCodeSent:
+		result.Status = VerifyCodeSent
+	case isRateLimited(result.Message):
+		result.Status = VerifyRateLimited
+	default:
+		result.Status = VerifyError
+	}
+	return result, nil
+}
+
+func isRateLimited(message string) bool {
+	message = strings.ToLower(message)
+	for _, hint := range rateLimitHints {
+		if strings.Contains(message, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+func requestVerify(ctx context.Context, client *http.Client, st *states.State, username, reqType string) (*models.ResponseRequireVerificate, error) {
+	url, exists := st.ExtraCfgURL(reqType)
+	if !exists || url == "" {
+		return nil, ErrVerifyUnsupported
+	}
+
+	timestamp := fmt.Sprintf("%d", currentTimeMillis())
+	schoolID := st.SchoolID()
+	authenticator := md5.Sum([]byte(schoolID + timestamp + constants.AuthKey))
+
+	reqData := models.RequireVerificate{
+		SchoolID:      schoolID,
+		Username:      username,
+		Timestamp:     timestamp,
+		Authenticator: strings.ToUpper(hex.EncodeToString(authenticator[:])),
+	}
+
+	jsonData, err := json.Marshal(reqData)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(jsonData)))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", constants.UserAgent)
+	req.Header.Set("Accept", "okhttp/3.4.1")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", reqType, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %s", reqType, resp.Status)
+	}
+
+	var result models.ResponseRequireVerificate
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%s: decoding response: %w", reqType, err)
+	}
+
+	return &result, nil
+}
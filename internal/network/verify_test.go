@@ -0,0 +1,97 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/models"
+	"github.com/Rsplwe/ESurfingDialer/internal/states"
+)
+
+// verifyServer answers every verification request with resp
+func verifyServer(t *testing.T, status int, resp models.ResponseRequireVerificate) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.RequireVerificate
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username == "" || req.Authenticator == "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRequestVerifyCode(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		resp   models.ResponseRequireVerificate
+		want   VerifyStatus
+	}{
+		{"sent", http.StatusOK, models.ResponseRequireVerificate{ResCode: "0", Phone: " 138****0000 "}, VerifyCodeSent},
+		{"too frequent", http.StatusOK, models.ResponseRequireVerificate{ResCode: "1", ResInfo: "获取验证码过于频繁，请稍后再试"}, VerifyRateLimited},
+		{"rate limit english", http.StatusOK, models.ResponseRequireVerificate{ResCode: "1", ResInfo: "Too many requests"}, VerifyRateLimited},
+		{"quota is not a cooldown", http.StatusOK, models.ResponseRequireVerificate{ResCode: "1", ResInfo: "daily SMS limit exceeded"}, VerifyError},
+		{"online limit is not a cooldown", http.StatusOK, models.ResponseRequireVerificate{ResCode: "1", ResInfo: "online limit reached"}, VerifyError},
+		{"refused", http.StatusOK, models.ResponseRequireVerificate{ResCode: "2", ResInfo: "user not found"}, VerifyError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := verifyServer(t, tt.status, tt.resp)
+			st := states.New()
+			st.SetExtraCfgURL(verifyCodeFunc, srv.URL)
+
+			res, err := RequestVerifyCode(context.Background(), srv.Client(), st, "13800000000")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Status != tt.want {
+				t.Errorf("status = %s, want %s", res.Status, tt.want)
+			}
+			if res.ResCode != tt.resp.ResCode {
+				t.Errorf("rescode = %q, want %q", res.ResCode, tt.resp.ResCode)
+			}
+			if tt.want == VerifyCodeSent && res.Phone != "138****0000" {
+				t.Errorf("phone = %q", res.Phone)
+			}
+		})
+	}
+}
+
+func TestRequestVerifyCodeFailures(t *testing.T) {
+	st := states.New()
+	res, err := RequestVerifyCode(context.Background(), http.DefaultClient, st, "13800000000")
+	if err != nil || res.Status != VerifyNotRequired {
+		t.Errorf("without the function: %+v, %v", res, err)
+	}
+
+	srv := verifyServer(t, http.StatusInternalServerError, models.ResponseRequireVerificate{})
+	st.SetExtraCfgURL(verifyCodeFunc, srv.URL)
+	res, err = RequestVerifyCode(context.Background(), srv.Client(), st, "13800000000")
+	if err == nil || res.Status != VerifyError {
+		t.Errorf("server error: %+v, %v", res, err)
+	}
+}
+
+func TestVerifyRequired(t *testing.T) {
+	for rescode, want := range map[string]bool{verifyStatusCode: true, "0": false} {
+		srv := verifyServer(t, http.StatusOK, models.ResponseRequireVerificate{ResCode: rescode})
+		st := states.New()
+		st.SetExtraCfgURL(verifyStatusFunc, srv.URL)
+		got, err := VerifyRequired(context.Background(), srv.Client(), st, "13800000000")
+		if err != nil || got != want {
+			t.Errorf("rescode %s: VerifyRequired() = %v, %v, want %v", rescode, got, err, want)
+		}
+	}
+
+	got, err := VerifyRequired(context.Background(), http.DefaultClient, states.New(), "13800000000")
+	if err != nil || got {
+		t.Errorf("without the function: VerifyRequired() = %v, %v", got, err)
+	}
+}
@@ -1,107 +1,194 @@
 package session
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/Rsplwe/ESurfingDialer/internal/cipher"
-	"github.com/Rsplwe/ESurfingDialer/internal/states"
 )
 
-var (
+var errNotInitialized = errors.New("session is not initialized")
+
+// Session holds the cipher negotiated with the portal for one login
+type Session struct {
+	mu          sync.RWMutex
 	initialized bool
 	cipherImpl  cipher.CipherInterface
-)
+	zsm         *ZSM
+	logger      *slog.Logger
+}
 
-// Initialize initializes the session with ZSM data
-func Initialize(zsm []byte) error {
-	fmt.Println("Initializing Session...")
-	var err error
-	initialized, err = load(zsm)
-	return err
+// New creates an uninitialized session
+func New() *Session {
+	return &Session{logger: slog.Default().With("component", "session")}
 }
 
-// IsInitialized returns whether the session is initialized
-func IsInitialized() bool {
-	return initialized
+// SetLogger sets the logger session messages are written to
+func (s *Session) SetLogger(logger *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = logger.With("component", "session")
 }
 
-func load(zsm []byte) (bool, error) {
-	if len(zsm) < 4 {
-		return false, fmt.Errorf("invalid zsm header")
-	}
+// Init initializes the session with ZSM data
+func (s *Session) Init(data []byte) error {
+	s.mu.RLock()
+	logger := s.logger
+	s.mu.RUnlock()
 
-	header := string(zsm[0:3])
-	keyLen := int(zsm[3])
-	pos := 4
+	logger.Info("Initializing session")
+	zsm, err := ParseZSM(data)
+	if err != nil {
+		return err
+	}
 
-	if pos+keyLen > len(zsm) {
-		return false, fmt.Errorf("invalid key length")
+	impl, err := cipher.GetInstance(zsm.AlgoID)
+	if err != nil {
+		saveBytesToFile(logger, fmt.Sprintf("algo_dump_%d.bin", currentTimeMillis()), data)
+		return err
 	}
 
-	key := string(zsm[pos : pos+keyLen])
-	pos += keyLen
+	logger.Info("Session initialized", "type", zsm.Header, "algo_id", zsm.AlgoID, "key_length", len(zsm.Key))
 
-	if pos >= len(zsm) {
-		return false, fmt.Errorf("invalid algo id length")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cipherImpl = impl
+	s.zsm = zsm
+	s.initialized = true
+	return nil
+}
+
+// IsInitialized returns whether the session is initialized
+func (s *Session) IsInitialized() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.initialized
+}
+
+// AlgoID returns the negotiated algorithm id, empty before Init
+func (s *Session) AlgoID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.zsm == nil {
+		return ""
 	}
+	return s.zsm.AlgoID
+}
 
-	algoIdLen := int(zsm[pos])
-	pos++
+// Key returns the session key from the ZSM blob, nil before Init
+func (s *Session) Key() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.zsm == nil {
+		return nil
+	}
+	return s.zsm.Key
+}
 
-	if pos+algoIdLen > len(zsm) {
-		return false, fmt.Errorf("invalid algo id")
+func (s *Session) current() (cipher.CipherInterface, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.initialized {
+		return nil, errNotInitialized
 	}
+	return s.cipherImpl, nil
+}
 
-	algoId := string(zsm[pos : pos+algoIdLen])
+// Decrypt decrypts hex string
+func (s *Session) Decrypt(hex string) (string, error) {
+	impl, err := s.current()
+	if err != nil {
+		return "", err
+	}
+	data, err := impl.Decrypt([]byte(hex))
+	if err != nil {
+		return "", fmt.Errorf("decrypt: %w", err)
+	}
+	return string(data), nil
+}
 
-	var err error
-	cipherImpl, err = cipher.GetInstance(algoId)
+// Encrypt encrypts text
+func (s *Session) Encrypt(text string) (string, error) {
+	impl, err := s.current()
+	if err != nil {
+		return "", err
+	}
+	data, err := impl.Encrypt([]byte(text))
 	if err != nil {
-		saveBytesToFile(fmt.Sprintf("algo_dump_%d.bin", currentTimeMillis()), zsm)
-		return false, err
+		return "", fmt.Errorf("encrypt: %w", err)
 	}
+	return string(data), nil
+}
+
+// Close frees the session, it can be initialized again with Init
+func (s *Session) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.initialized = false
+	s.cipherImpl = nil
+	s.zsm = nil
+}
 
-	states.AlgoID = algoId
-	fmt.Printf("Type: %s\n", header)
-	fmt.Printf("Algo Id: %s\n", algoId)
-	fmt.Printf("Key: %s\n", key)
+// defaultSession backs the deprecated package-level functions
+var defaultSession = New()
 
-	return true, nil
+// Initialize initializes the default session with ZSM data.
+//
+// Deprecated: create a Session with New and call Init.
+func Initialize(zsm []byte) error {
+	return defaultSession.Init(zsm)
 }
 
-// Decrypt decrypts hex string
-func Decrypt(hex string) string {
-	return cipherImpl.Decrypt(hex)
+// IsInitialized returns whether the default session is initialized.
+//
+// Deprecated: use Session.IsInitialized.
+func IsInitialized() bool {
+	return defaultSession.IsInitialized()
 }
 
-// Encrypt encrypts text
-func Encrypt(text string) string {
-	return cipherImpl.Encrypt(text)
+// Decrypt decrypts hex string with the default session.
+//
+// Deprecated: use Session.Decrypt.
+func Decrypt(hex string) (string, error) {
+	return defaultSession.Decrypt(hex)
+}
+
+// Encrypt encrypts text with the default session.
+//
+// Deprecated: use Session.Encrypt.
+func Encrypt(text string) (string, error) {
+	return defaultSession.Encrypt(text)
 }
 
-// Free frees the session
+// Free frees the default session.
+//
+// Deprecated: use Session.Close.
 func Free() {
-	initialized = false
+	defaultSession.Close()
 }
 
-func saveBytesToFile(fileName string, data []byte) {
+func saveBytesToFile(logger *slog.Logger, fileName string, data []byte) {
 	file, err := os.Create(fileName)
 	if err != nil {
-		fmt.Printf("Error creating file: %v\n", err)
+		logger.Error("Creating dump file failed", "error", err)
 		return
 	}
 	defer file.Close()
 
 	_, err = file.Write(data)
 	if err != nil {
-		fmt.Printf("Error writing file: %v\n", err)
+		logger.Error("Writing dump file failed", "error", err)
 		return
 	}
 
 	absPath, _ := os.Getwd()
-	fmt.Printf("Please submit issue to https://github.com/Rsplwe/ESurfingDialer/issues and attach the file %s/%s\n", absPath, fileName)
+	logger.Error("Unknown algorithm, please submit an issue to https://github.com/Rsplwe/ESurfingDialer/issues and attach the dump file",
+		"file", absPath+"/"+fileName)
+	logger.Info(fmt.Sprintf("Run \"%s dump inspect %s\" to view its contents", os.Args[0], fileName))
 }
 
 func currentTimeMillis() int64 {
@@ -0,0 +1,53 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMalformedZSM is wrapped by every error ParseZSM returns
+var ErrMalformedZSM = errors.New("malformed zsm")
+
+// ZSM is the session blob returned by the ticket URL:
+// 3 byte header, key length, key, algo id length, algo id, payload
+type ZSM struct {
+	Header  string
+	Key     []byte
+	AlgoID  string
+	Payload []byte // Trailing bytes after the algo id, usually empty
+}
+
+// ParseZSM parses a ZSM blob, the returned slices are copies of data
+func ParseZSM(data []byte) (*ZSM, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%w: header needs 4 bytes, got %d", ErrMalformedZSM, len(data))
+	}
+
+	z := &ZSM{Header: string(data[0:3])}
+	keyLen := int(data[3])
+	pos := 4
+
+	if pos+keyLen > len(data) {
+		return nil, fmt.Errorf("%w: key length %d at offset 3 exceeds remaining %d bytes", ErrMalformedZSM, keyLen, len(data)-pos)
+	}
+	z.Key = append([]byte{}, data[pos:pos+keyLen]...)
+	pos += keyLen
+
+	if pos >= len(data) {
+		return nil, fmt.Errorf("%w: missing algo id length at offset %d", ErrMalformedZSM, pos)
+	}
+	algoIDLen := int(data[pos])
+	pos++
+
+	if algoIDLen == 0 {
+		return nil, fmt.Errorf("%w: empty algo id at offset %d", ErrMalformedZSM, pos-1)
+	}
+	if pos+algoIDLen > len(data) {
+		return nil, fmt.Errorf("%w: algo id length %d at offset %d exceeds remaining %d bytes", ErrMalformedZSM, algoIDLen, pos-1, len(data)-pos)
+	}
+	z.AlgoID = string(data[pos : pos+algoIDLen])
+	pos += algoIDLen
+
+	z.Payload = append([]byte{}, data[pos:]...)
+	return z, nil
+}
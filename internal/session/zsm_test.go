@@ -0,0 +1,84 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// zsmBlob builds a ZSM blob from its fields
+func zsmBlob(header string, key []byte, algoID string, payload []byte) []byte {
+	b := []byte(header)
+	b = append(b, byte(len(key)))
+	b = append(b, key...)
+	b = append(b, byte(len(algoID)))
+	b = append(b, algoID...)
+	return append(b, payload...)
+}
+
+const testAlgoID = "CAFBCBAD-B6E7-4CAB-8A67-14D39F00CE1E"
+
+func TestParseZSM(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 16)
+	data := zsmBlob("ZSM", key, testAlgoID, []byte{1, 2})
+
+	z, err := ParseZSM(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if z.Header != "ZSM" || !bytes.Equal(z.Key, key) || z.AlgoID != testAlgoID || !bytes.Equal(z.Payload, []byte{1, 2}) {
+		t.Fatalf("ParseZSM() = %+v", z)
+	}
+
+	// The result must not alias the input
+	data[4] = 0
+	if z.Key[0] != 0x42 {
+		t.Fatal("key aliases the input")
+	}
+}
+
+func TestParseZSMErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"short header", []byte("ZS"), "header needs 4 bytes, got 2"},
+		{"key overrun", append([]byte("ZSM"), 16, 1, 2, 3), "key length 16 at offset 3 exceeds remaining 3 bytes"},
+		{"missing algo length", append([]byte("ZSM"), 2, 1, 2), "missing algo id length at offset 6"},
+		{"empty algo id", append([]byte("ZSM"), 2, 1, 2, 0), "empty algo id at offset 6"},
+		{"algo id overrun", append([]byte("ZSM"), 0, 36, 'A', 'B'), "algo id length 36 at offset 4 exceeds remaining 2 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseZSM(tt.data)
+			if !errors.Is(err, ErrMalformedZSM) {
+				t.Fatalf("ParseZSM() error = %v, want ErrMalformedZSM", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("ParseZSM() error = %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzParseZSM(f *testing.F) {
+	f.Add(zsmBlob("ZSM", bytes.Repeat([]byte{0x42}, 16), testAlgoID, nil))
+	f.Add(zsmBlob("ZSM", nil, "A", []byte("payload")))
+	f.Add([]byte("ZSM"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		z, err := ParseZSM(data)
+		if err != nil {
+			if !errors.Is(err, ErrMalformedZSM) {
+				t.Fatalf("error %v does not wrap ErrMalformedZSM", err)
+			}
+			return
+		}
+		// A parsed blob must serialize back to the input
+		if got := zsmBlob(z.Header, z.Key, z.AlgoID, z.Payload); !bytes.Equal(got, data) {
+			t.Fatalf("re-encoded %x, input %x", got, data)
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package smscode
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultInterval is the polling interval of File and HTTP
+const defaultInterval = time.Second
+
+// Stdin is shared by every provider, a single reader hands its lines out
+var (
+	stdinOnce  sync.Once
+	stdinLines chan string
+)
+
+func readStdin() <-chan string {
+	stdinOnce.Do(func() {
+		stdinLines = make(chan string)
+		go func() {
+			defer close(stdinLines)
+			reader := bufio.NewReader(os.Stdin)
+			for {
+				input, err := reader.ReadString('\n')
+				if line := strings.TrimSpace(input); line != "" {
+					stdinLines <- line
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	})
+	return stdinLines
+}
+
+// Stdin prompts for the code on stdin, it fails once stdin is closed
+type Stdin struct{}
+
+func (Stdin) Code(ctx context.Context, user string) (string, error) {
+	fmt.Print("Input Code: ")
+	select {
+	case code, ok := <-readStdin():
+		if !ok {
+			return "", ErrNoCode
+		}
+		return code, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// File reads the code from Path. A FIFO is read until a line arrives, a
+// regular file is polled until it has content and is removed once read. A
+// file last written before Code was called holds a stale code and is removed.
+type File struct {
+	Path     string
+	Interval time.Duration // Polling interval, one second by default
+}
+
+func (f *File) Code(ctx context.Context, user string) (string, error) {
+	if fi, err := os.Stat(f.Path); err == nil && fi.Mode()&os.ModeNamedPipe != 0 {
+		return f.readFIFO(ctx)
+	}
+
+	// Modification times may only have a one second resolution
+	start := time.Now().Truncate(time.Second)
+	interval := f.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	for {
+		if fi, err := os.Stat(f.Path); err == nil && fi.ModTime().Before(start) {
+			os.Remove(f.Path)
+		}
+		data, err := os.ReadFile(f.Path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		if code := firstLine(string(data)); code != "" {
+			// A stale code must not be used for the next login
+			os.Remove(f.Path)
+			return code, nil
+		}
+		if err := sleep(ctx, interval); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (f *File) readFIFO(ctx context.Context) (string, error) {
+	// Opening read-write keeps the FIFO from reporting EOF while no writer is attached
+	fifo, err := os.OpenFile(f.Path, os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+	defer fifo.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		fifo.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	scanner := bufio.NewScanner(fifo)
+	for scanner.Scan() {
+		if code := strings.TrimSpace(scanner.Text()); code != "" {
+			return code, nil
+		}
+	}
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrNoCode
+}
+
+// HTTP polls URL with the user as a query parameter. A 200 response body is
+// the code, an empty body or a server or network error is retried after
+// Interval. A 4xx response fails at once, retrying cannot fix it.
+type HTTP struct {
+	URL      string
+	Interval time.Duration // Polling interval, one second by default
+	Client   *http.Client  // http.DefaultClient when nil
+}
+
+func (h *HTTP) Code(ctx context.Context, user string) (string, error) {
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("user", user)
+	u.RawQuery = q.Encode()
+
+	interval := h.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	var lastErr error
+	for {
+		code, permanent, err := h.poll(ctx, u.String())
+		if err == nil && code != "" {
+			return code, nil
+		}
+		if permanent {
+			return "", err
+		}
+		if err != nil {
+			lastErr = err
+		}
+		if err := sleep(ctx, interval); err != nil {
+			if lastErr != nil {
+				return "", fmt.Errorf("%w, last poll error: %v", err, lastErr)
+			}
+			return "", err
+		}
+	}
+}
+
+// poll requests the code once, permanent reports an error retrying cannot fix
+func (h *HTTP) poll(ctx context.Context, url string) (code string, permanent bool, err error) {
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", true, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return "", true, fmt.Errorf("unexpected status %s", resp.Status)
+	case resp.StatusCode >= 500:
+		return "", false, fmt.Errorf("unexpected status %s", resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return "", false, nil // No code yet, such as 204
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", false, err
+	}
+	return firstLine(string(body)), false, nil
+}
+
+// Command runs Args with ESURFING_USER set, the first line of its stdout is the code
+type Command struct {
+	Args []string
+}
+
+func (c *Command) Code(ctx context.Context, user string) (string, error) {
+	cmd := exec.CommandContext(ctx, c.Args[0], c.Args[1:]...)
+	cmd.Env = append(os.Environ(), "ESURFING_USER="+user)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", c.Args[0], err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", c.Args[0], err)
+	}
+	code := firstLine(string(out))
+	if code == "" {
+		return "", ErrNoCode
+	}
+	return code, nil
+}
@@ -0,0 +1,139 @@
+// Package smscode supplies SMS verification codes to the client without
+// requiring a terminal, from stdin, a file or FIFO, a local HTTP endpoint
+// or an external command.
+package smscode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNoCode is returned when a provider has no code to give
+var ErrNoCode = errors.New("no verification code entered")
+
+// Provider supplies the verification code sent to user
+type Provider interface {
+	Code(ctx context.Context, user string) (string, error)
+}
+
+// Parse creates a provider from a spec:
+//
+//	stdin            prompt on stdin
+//	control          wait for a code submitted over the control API
+//	file:PATH        read a file or FIFO, a regular file is removed once read
+//	http://URL       poll an HTTP endpoint, any 200 response body is the code
+//	command:CMDLINE  run a shell command, its first stdout line is the code
+func Parse(spec string) (Provider, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "", "stdin":
+		return Stdin{}, nil
+	case "control":
+		return Manual{}, nil
+	case "file":
+		if arg == "" {
+			return nil, fmt.Errorf("sms provider %q: missing path", spec)
+		}
+		return &File{Path: arg}, nil
+	case "http", "https":
+		return &HTTP{URL: spec}, nil
+	case "command":
+		if strings.TrimSpace(arg) == "" {
+			return nil, fmt.Errorf("sms provider %q: missing command", spec)
+		}
+		return &Command{Args: []string{"sh", "-c", arg}}, nil
+	default:
+		return nil, fmt.Errorf("unknown sms provider %q", spec)
+	}
+}
+
+// Manual waits until ctx is done, the code is expected to arrive out of
+// band such as through the control API
+type Manual struct{}
+
+func (Manual) Code(ctx context.Context, user string) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+// Retry runs Provider up to Attempts times, each attempt bounded by Timeout
+type Retry struct {
+	Provider Provider
+	Timeout  time.Duration // Zero waits until ctx is done
+	Attempts int           // Values below 1 make a single attempt
+	Delay    time.Duration // Pause between attempts
+
+	// Before runs ahead of every attempt after the first, such as to request a new code
+	Before func(ctx context.Context) error
+}
+
+func (r *Retry) Code(ctx context.Context, user string) (string, error) {
+	attempts := max(r.Attempts, 1)
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 && r.Delay > 0 {
+			if err := sleep(ctx, r.Delay); err != nil {
+				return "", err
+			}
+		}
+		if i > 0 && r.Before != nil {
+			if err := r.Before(ctx); err != nil {
+				return "", err
+			}
+		}
+
+		var code string
+		code, err = r.attempt(ctx, user)
+		if err == nil {
+			return code, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+	}
+	if attempts > 1 {
+		return "", fmt.Errorf("no verification code after %d attempts: %w", attempts, err)
+	}
+	return "", err
+}
+
+func (r *Retry) attempt(ctx context.Context, user string) (string, error) {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	code, err := r.Provider.Code(ctx, user)
+	if err != nil {
+		return "", err
+	}
+	if code = strings.TrimSpace(code); code == "" {
+		return "", ErrNoCode
+	}
+	return code, nil
+}
+
+// sleep pauses for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// firstLine returns the first non-empty trimmed line of s
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
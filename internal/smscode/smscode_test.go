@@ -0,0 +1,261 @@
+package smscode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", "smscode.Stdin"},
+		{"stdin", "smscode.Stdin"},
+		{"control", "smscode.Manual"},
+		{"file:/tmp/code", "*smscode.File"},
+		{"http://127.0.0.1:8000/code", "*smscode.HTTP"},
+		{"https://example.com/code", "*smscode.HTTP"},
+		{"command:echo 1", "*smscode.Command"},
+	}
+	for _, tt := range tests {
+		p, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := fmt.Sprintf("%T", p); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+	for _, spec := range []string{"file:", "command: ", "sms:123"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestFileWaits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "code")
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(path, []byte("\n 123456 \nignored\n"), 0600)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	code, err := (&File{Path: path, Interval: 10 * time.Millisecond}).Code(ctx, "user")
+	if err != nil || code != "123456" {
+		t.Fatalf("Code() = %q, %v", code, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("the code file was not removed")
+	}
+}
+
+func TestFileStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "code")
+	if err := os.WriteFile(path, []byte("111111\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(path, []byte("222222\n"), 0600)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	code, err := (&File{Path: path, Interval: 10 * time.Millisecond}).Code(ctx, "user")
+	if err != nil || code != "222222" {
+		t.Fatalf("Code() = %q, %v, want the code written after the call", code, err)
+	}
+}
+
+func TestFileTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "code")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := (&File{Path: path, Interval: 10 * time.Millisecond}).Code(ctx, "user"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Code() error = %v, want a deadline", err)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	var polls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("user") != "13800000000" {
+			http.Error(w, "bad user", http.StatusBadRequest)
+			return
+		}
+		switch polls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusNoContent)
+		case 2:
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		case 3:
+			w.WriteHeader(http.StatusOK) // Empty body
+		default:
+			fmt.Fprintln(w, "654321")
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	h := &HTTP{URL: srv.URL + "/code?token=x", Interval: 10 * time.Millisecond}
+	code, err := h.Code(ctx, "13800000000")
+	if err != nil || code != "654321" {
+		t.Fatalf("Code() = %q, %v", code, err)
+	}
+	if n := polls.Load(); n != 4 {
+		t.Errorf("%d polls, want 4", n)
+	}
+}
+
+func TestHTTPClientError(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusNotFound} {
+		var polls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			polls.Add(1)
+			w.WriteHeader(status)
+		}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := (&HTTP{URL: srv.URL, Interval: 10 * time.Millisecond}).Code(ctx, "user")
+		cancel()
+		srv.Close()
+		if err == nil || !strings.Contains(err.Error(), fmt.Sprint(status)) || errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("status %d: error = %v, want the status at once", status, err)
+		}
+		if n := polls.Load(); n != 1 {
+			t.Errorf("status %d: %d polls, want 1", status, n)
+		}
+	}
+}
+
+func TestHTTPLastError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := (&HTTP{URL: srv.URL, Interval: 10 * time.Millisecond}).Code(ctx, "user")
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("Code() error = %v, want the deadline with the last poll error", err)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	ctx := context.Background()
+	code, err := (&Command{Args: []string{"sh", "-c", `echo "$ESURFING_USER-42"`}}).Code(ctx, "alice")
+	if err != nil || code != "alice-42" {
+		t.Errorf("Code() = %q, %v", code, err)
+	}
+
+	if _, err := (&Command{Args: []string{"sh", "-c", "true"}}).Code(ctx, "alice"); !errors.Is(err, ErrNoCode) {
+		t.Errorf("empty output: error = %v, want ErrNoCode", err)
+	}
+
+	_, err = (&Command{Args: []string{"sh", "-c", "echo gateway offline >&2; exit 3"}}).Code(ctx, "alice")
+	if err == nil || !strings.Contains(err.Error(), "gateway offline") {
+		t.Errorf("failure: error = %v, want the stderr message", err)
+	}
+}
+
+// countProvider fails until its attempt number reaches ok, zero never succeeds
+type countProvider struct {
+	calls int
+	ok    int
+}
+
+func (p *countProvider) Code(ctx context.Context, user string) (string, error) {
+	p.calls++
+	if p.ok > 0 && p.calls >= p.ok {
+		return " 112233 ", nil
+	}
+	return "", errors.New("no code")
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		ok       int
+		calls    int
+		before   int
+		wantErr  bool
+	}{
+		{"first attempt", 3, 1, 1, 0, false},
+		{"third attempt", 3, 3, 3, 2, false},
+		{"all attempts fail", 3, 0, 3, 2, true},
+		{"zero attempts makes one", 0, 0, 1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &countProvider{ok: tt.ok}
+			before := 0
+			r := &Retry{Provider: p, Attempts: tt.attempts, Before: func(ctx context.Context) error {
+				before++
+				return nil
+			}}
+			code, err := r.Code(context.Background(), "user")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Code() = %q, %v", code, err)
+			}
+			if err == nil && code != "112233" {
+				t.Errorf("code = %q, want it trimmed", code)
+			}
+			if p.calls != tt.calls || before != tt.before {
+				t.Errorf("%d calls and %d Before, want %d and %d", p.calls, before, tt.calls, tt.before)
+			}
+		})
+	}
+}
+
+func TestRetryTimeout(t *testing.T) {
+	calls := 0
+	r := &Retry{
+		Provider: providerFunc(func(ctx context.Context, user string) (string, error) {
+			calls++
+			<-ctx.Done()
+			return "", ctx.Err()
+		}),
+		Timeout:  10 * time.Millisecond,
+		Attempts: 2,
+	}
+	_, err := r.Code(context.Background(), "user")
+	if !errors.Is(err, context.DeadlineExceeded) || calls != 2 {
+		t.Fatalf("Code() error = %v after %d calls, want a deadline after 2", err, calls)
+	}
+}
+
+func TestRetryBeforeFails(t *testing.T) {
+	errCooldown := errors.New("cooldown")
+	r := &Retry{
+		Provider: &countProvider{},
+		Attempts: 3,
+		Before:   func(ctx context.Context) error { return errCooldown },
+	}
+	if _, err := r.Code(context.Background(), "user"); !errors.Is(err, errCooldown) {
+		t.Fatalf("Code() error = %v, want the Before error", err)
+	}
+}
+
+type providerFunc func(ctx context.Context, user string) (string, error)
+
+func (f providerFunc) Code(ctx context.Context, user string) (string, error) { return f(ctx, user) }
@@ -1,35 +1,132 @@
 package states
 
 import (
+	"log/slog"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/Rsplwe/ESurfingDialer/internal/utils"
 	"github.com/google/uuid"
 )
 
-var (
-	ClientID    string
-	AlgoID      string
-	MacAddress  string
-	Ticket      string
-	UserIP      string
-	ACIP        string
-	IsRunning   bool = true
-	SchoolID    string
-	Domain      string
-	Area        string
-	TicketURL   string
-	AuthURL     string
-	ExtraCfgURL = make(map[string]string)
-	IsLogged    bool
-	Interface   string // Network interface name for binding (e.g., eth0, wan)
-)
+// State holds the dialer state of one client, it is safe for concurrent use
+type State struct {
+	mu          sync.RWMutex
+	clientID    string
+	algoID      string
+	macAddress  string
+	ticket      string
+	userIP      string
+	acIP        string
+	schoolID    string
+	domain      string
+	area        string
+	ticketURL   string
+	authURL     string
+	extraCfgURL map[string]string
+	iface       string // Network interface name for binding (e.g., eth0, wan)
+	logger      *slog.Logger
+
+	running atomic.Bool
+	logged  atomic.Bool
+}
+
+// New creates a running State
+func New() *State {
+	s := &State{
+		extraCfgURL: make(map[string]string),
+		logger:      slog.Default(),
+	}
+	s.running.Store(true)
+	return s
+}
 
-// RefreshStates refreshes the client state with new random values
-func RefreshStates() {
-	ClientID = strings.ToLower(uuid.New().String())
-	AlgoID = "00000000-0000-0000-0000-000000000000"
-	if MacAddress == "" {
-		MacAddress = utils.RandomMACAddress()
+// Refresh refreshes the client state with new random values
+func (s *State) Refresh() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clientID = strings.ToLower(uuid.New().String())
+	s.algoID = "00000000-0000-0000-0000-000000000000"
+	if s.macAddress == "" {
+		s.macAddress = utils.RandomMACAddress()
 	}
 }
+
+func (s *State) get(field *string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return *field
+}
+
+func (s *State) set(field *string, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*field = value
+}
+
+// Field accessors, each takes the state lock
+func (s *State) ClientID() string       { return s.get(&s.clientID) }
+func (s *State) AlgoID() string         { return s.get(&s.algoID) }
+func (s *State) SetAlgoID(v string)     { s.set(&s.algoID, v) }
+func (s *State) MacAddress() string     { return s.get(&s.macAddress) }
+func (s *State) SetMacAddress(v string) { s.set(&s.macAddress, v) }
+func (s *State) Ticket() string         { return s.get(&s.ticket) }
+func (s *State) SetTicket(v string)     { s.set(&s.ticket, v) }
+func (s *State) UserIP() string         { return s.get(&s.userIP) }
+func (s *State) SetUserIP(v string)     { s.set(&s.userIP, v) }
+func (s *State) ACIP() string           { return s.get(&s.acIP) }
+func (s *State) SetACIP(v string)       { s.set(&s.acIP, v) }
+func (s *State) SchoolID() string       { return s.get(&s.schoolID) }
+func (s *State) SetSchoolID(v string)   { s.set(&s.schoolID, v) }
+func (s *State) Domain() string         { return s.get(&s.domain) }
+func (s *State) SetDomain(v string)     { s.set(&s.domain, v) }
+func (s *State) Area() string           { return s.get(&s.area) }
+func (s *State) SetArea(v string)       { s.set(&s.area, v) }
+func (s *State) TicketURL() string      { return s.get(&s.ticketURL) }
+func (s *State) SetTicketURL(v string)  { s.set(&s.ticketURL, v) }
+func (s *State) AuthURL() string        { return s.get(&s.authURL) }
+func (s *State) SetAuthURL(v string)    { s.set(&s.authURL, v) }
+func (s *State) Interface() string      { return s.get(&s.iface) }
+func (s *State) SetInterface(v string)  { s.set(&s.iface, v) }
+
+// Logger returns the logger for messages about this client
+func (s *State) Logger() *slog.Logger {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.logger
+}
+
+// SetLogger sets the logger for messages about this client
+func (s *State) SetLogger(logger *slog.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = logger
+}
+
+// ExtraCfgURL returns the URL of an enabled portal function
+func (s *State) ExtraCfgURL(name string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	url, ok := s.extraCfgURL[name]
+	return url, ok
+}
+
+// SetExtraCfgURL records the URL of an enabled portal function
+func (s *State) SetExtraCfgURL(name, url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.extraCfgURL[name] = url
+}
+
+// IsRunning returns whether the client loop should keep running
+func (s *State) IsRunning() bool { return s.running.Load() }
+
+// SetRunning sets whether the client loop should keep running
+func (s *State) SetRunning(v bool) { s.running.Store(v) }
+
+// IsLogged returns whether the client is logged in
+func (s *State) IsLogged() bool { return s.logged.Load() }
+
+// SetLogged sets whether the client is logged in
+func (s *State) SetLogged(v bool) { s.logged.Store(v) }
@@ -0,0 +1,243 @@
+// Package esurfing drives China Telecom ESurfing campus authentication
+// so other Go programs can embed the dialer.
+//
+//	d, err := esurfing.New(
+//		esurfing.WithCredentials(user, password),
+//		esurfing.WithCodeProvider(esurfing.CodeFunc(askUser)),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	defer d.Close()
+//	if err := d.Login(ctx); err != nil {
+//		return err
+//	}
+//	for {
+//		next, err := d.Keepalive(ctx)
+//		...
+//	}
+package esurfing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/client"
+	"github.com/Rsplwe/ESurfingDialer/internal/logging"
+	"github.com/Rsplwe/ESurfingDialer/internal/models"
+	"github.com/Rsplwe/ESurfingDialer/internal/network"
+	"github.com/Rsplwe/ESurfingDialer/internal/states"
+)
+
+var (
+	// ErrNoCredentials is returned by New when user or password is missing
+	ErrNoCredentials = errors.New("esurfing: user and password are required")
+	// ErrNotLoggedIn is returned by Keepalive and Logout before a successful Login
+	ErrNotLoggedIn = client.ErrNotLoggedIn
+	// ErrClosed is returned by Login, Keepalive and Logout after Close
+	ErrClosed = errors.New("esurfing: dialer closed")
+
+	// Reasons Login fails with when the portal refuses the login, match them with errors.Is
+	ErrLoginRejected    = client.ErrLoginRejected
+	ErrWrongPassword    = client.ErrWrongPassword
+	ErrAccountSuspended = client.ErrAccountSuspended
+	ErrArrears          = client.ErrArrears
+	ErrLoginLimit       = client.ErrLoginLimit
+	ErrBadCode          = client.ErrBadCode
+
+	// ErrCodeRequired is returned by Login when the portal asks for an SMS
+	// verification code and no WithCodeProvider was set
+	ErrCodeRequired = errors.New("esurfing: an SMS verification code is required, set WithCodeProvider")
+)
+
+// LoginError is a login refused by the portal, its Reason tells why
+type LoginError = client.LoginError
+
+// CodeProvider supplies the SMS verification code sent to user, it is called
+// while Login waits and should return once ctx is cancelled
+type CodeProvider interface {
+	Code(ctx context.Context, user string) (string, error)
+}
+
+// CodeFunc adapts a function to a CodeProvider
+type CodeFunc func(ctx context.Context, user string) (string, error)
+
+func (f CodeFunc) Code(ctx context.Context, user string) (string, error) {
+	return f(ctx, user)
+}
+
+// noCode is the default CodeProvider, a library must not read the host program's stdin
+type noCode struct{}
+
+func (noCode) Code(ctx context.Context, user string) (string, error) {
+	return "", ErrCodeRequired
+}
+
+// Dialer is one campus authentication client
+type Dialer struct {
+	mu     sync.Mutex // Serializes Login, Keepalive and Logout
+	client *client.Client
+	state  *states.State
+	events chan Event
+	closed bool
+}
+
+// New creates a Dialer, WithCredentials is required
+func New(opts ...Option) (*Dialer, error) {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.user == "" || cfg.password == "" {
+		return nil, ErrNoCredentials
+	}
+	probes, err := network.ParseProbes(cfg.probes)
+	if err != nil {
+		return nil, fmt.Errorf("esurfing: %w", err)
+	}
+
+	st := states.New()
+	if cfg.mac != "" {
+		st.SetMacAddress(cfg.mac)
+	}
+	st.SetInterface(cfg.iface)
+	st.Refresh()
+
+	c := client.New(&models.Options{
+		LoginUser:     cfg.user,
+		LoginPassword: cfg.password,
+		SmsCode:       cfg.smsCode,
+	}, st)
+	logger := cfg.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	// The handler is not ours, redact secrets whatever it is configured with
+	c.SetLogger(slog.New(logging.RedactHandler(logger.Handler())))
+	if cfg.transport != nil {
+		c.SetTransport(cfg.transport)
+	}
+	if cfg.codes != nil {
+		c.SetCodeProvider(cfg.codes)
+	} else {
+		c.SetCodeProvider(noCode{})
+	}
+	c.SetProbes(network.ProbeConfig{Probes: probes, Parallel: cfg.parallel})
+
+	return &Dialer{
+		client: c,
+		state:  st,
+		events: make(chan Event, eventBuffer),
+	}, nil
+}
+
+// Login authorizes with the portal, it returns nil without a new login
+// when the network is already connected
+func (d *Dialer) Login(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := d.client.Login(ctx); err != nil {
+		d.emit(Event{Type: EventError, Err: err})
+		return err
+	}
+	if d.state.IsLogged() {
+		d.emit(Event{Type: EventLoggedIn})
+	}
+	return nil
+}
+
+// Keepalive sends a heartbeat and returns how long to wait before the next one
+func (d *Dialer) Keepalive(ctx context.Context) (time.Duration, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return 0, ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	next, err := d.client.Keepalive(ctx)
+	if err != nil {
+		d.emit(Event{Type: EventError, Err: err})
+		return next, err
+	}
+	d.emit(Event{Type: EventKeepalive, Next: next})
+	return next, nil
+}
+
+// Logout terminates the authorized session
+func (d *Dialer) Logout(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	err := d.client.Logout(ctx)
+	if errors.Is(err, client.ErrNotLoggedIn) {
+		return err
+	}
+	d.emit(Event{Type: EventLoggedOut, Err: err})
+	return err
+}
+
+// LoggedIn reports whether the dialer holds an authorized session
+func (d *Dialer) LoggedIn() bool {
+	return d.state.IsLogged()
+}
+
+// UserIP returns the client IP assigned by the portal
+func (d *Dialer) UserIP() string {
+	return d.state.UserIP()
+}
+
+// ACIP returns the access controller IP of the portal
+func (d *Dialer) ACIP() string {
+	return d.state.ACIP()
+}
+
+// Events returns the channel dialer events are delivered on, it is closed by Close.
+// Events are dropped when the channel is full.
+func (d *Dialer) Events() <-chan Event {
+	return d.events
+}
+
+// Close frees the session and closes the event channel, it does not log out.
+// Login, Keepalive and Logout fail with ErrClosed afterwards.
+func (d *Dialer) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	d.closed = true
+	d.client.Close()
+	close(d.events)
+}
+
+// emit delivers an event without blocking, d.mu must be held
+func (d *Dialer) emit(e Event) {
+	if d.closed {
+		return
+	}
+	e.Time = time.Now()
+	select {
+	case d.events <- e:
+	default:
+	}
+}
@@ -0,0 +1,280 @@
+package esurfing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Rsplwe/ESurfingDialer/internal/cipher"
+	"github.com/Rsplwe/ESurfingDialer/internal/constants"
+)
+
+// fakePortal redirects probes to its config until a login and speaks the
+// ticket, auth, keep and term endpoints with a registered cipher. With
+// verify set it also asks for an SMS verification code.
+type fakePortal struct {
+	*httptest.Server
+	algoID string
+	impl   cipher.CipherInterface
+	verify bool
+	logged atomic.Bool
+
+	mu    sync.Mutex
+	codes []string // Verification codes sent with logins
+}
+
+func newFakePortal(t *testing.T, verify bool) *fakePortal {
+	t.Helper()
+	algos := cipher.ListAlgorithms()
+	if len(algos) == 0 {
+		t.Fatal("no registered algorithm")
+	}
+	impl, err := cipher.GetInstance(algos[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakePortal{algoID: algos[0].ID, impl: impl, verify: verify}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/generate_204", func(w http.ResponseWriter, r *http.Request) {
+		if p.logged.Load() {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		funcfg := ""
+		if p.verify {
+			funcfg = fmt.Sprintf(`<funcfg><QueryVerificateCodeStatus enable="1" url="%s/verify/status"/><QueryAuthCode enable="1" url="%s/verify/code"/></funcfg>`, p.URL, p.URL)
+		}
+		fmt.Fprintf(w, "<html>%s<config><auth-url>%s/auth</auth-url><ticket-url>%s/ticket?wlanuserip=10.0.0.2&amp;wlanacip=10.0.0.1</ticket-url>%s</config>%s</html>",
+			constants.PortalStartTag, p.URL, p.URL, funcfg, constants.PortalEndTag)
+	})
+	mux.HandleFunc("/verify/status", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"rescode": "11062000"})
+	})
+	mux.HandleFunc("/verify/code", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"rescode": "0", "phone": "138****0000"})
+	})
+	mux.HandleFunc("/ticket", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := p.decrypt(r); err != nil {
+			// The session request carries the plain algo id
+			w.Write(p.zsm())
+			return
+		}
+		p.reply(w, "<response><ticket>TICKET</ticket></response>")
+	})
+	mux.HandleFunc("/auth", func(w http.ResponseWriter, r *http.Request) {
+		body, err := p.decrypt(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		code := ""
+		if _, rest, ok := strings.Cut(string(body), "<verify>"); ok {
+			code, _, _ = strings.Cut(rest, "</verify>")
+		}
+		p.mu.Lock()
+		p.codes = append(p.codes, code)
+		p.mu.Unlock()
+		if p.verify && code == "" {
+			p.reply(w, "<response><rescode>11062000</rescode></response>")
+			return
+		}
+		p.logged.Store(true)
+		p.reply(w, fmt.Sprintf("<response><rescode>0</rescode><keep-url>%s/keep</keep-url><term-url>%s/term</term-url><keep-retry>1</keep-retry></response>", p.URL, p.URL))
+	})
+	mux.HandleFunc("/keep", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := p.decrypt(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p.reply(w, "<response><rescode>0</rescode><interval>2</interval></response>")
+	})
+	mux.HandleFunc("/term", func(w http.ResponseWriter, r *http.Request) {
+		p.logged.Store(false)
+		p.reply(w, "<response><rescode>0</rescode></response>")
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *fakePortal) zsm() []byte {
+	key := bytes.Repeat([]byte{0x42}, 16)
+	b := append([]byte("ZSM"), byte(len(key)))
+	b = append(b, key...)
+	b = append(b, byte(len(p.algoID)))
+	return append(b, p.algoID...)
+}
+
+func (p *fakePortal) decrypt(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return p.impl.Decrypt(body)
+}
+
+func (p *fakePortal) reply(w http.ResponseWriter, xml string) {
+	data, err := p.impl.Encrypt([]byte(xml))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(data)
+}
+
+func (p *fakePortal) sentCodes() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.codes...)
+}
+
+func newTestDialer(t *testing.T, portal *fakePortal, opts ...Option) *Dialer {
+	t.Helper()
+	opts = append([]Option{
+		WithCredentials("13800000000", "password"),
+		WithProbes(portal.URL + "/generate_204"),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}, opts...)
+	d, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(d.Close)
+	return d
+}
+
+// nextEvent returns the next queued event, events are sent before the call returns
+func nextEvent(t *testing.T, d *Dialer) Event {
+	t.Helper()
+	select {
+	case e := <-d.Events():
+		return e
+	default:
+		t.Fatal("no event queued")
+		return Event{}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(WithCredentials("13800000000", "")); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("New without password: %v, want ErrNoCredentials", err)
+	}
+	if _, err := New(WithCredentials("13800000000", "password"), WithProbes("nosuchpreset")); err == nil {
+		t.Error("New with an unknown probe preset succeeded")
+	}
+}
+
+func TestDialer(t *testing.T) {
+	ctx := context.Background()
+	portal := newFakePortal(t, false)
+	d := newTestDialer(t, portal)
+
+	if _, err := d.Keepalive(ctx); !errors.Is(err, ErrNotLoggedIn) {
+		t.Fatalf("Keepalive before Login: %v, want ErrNotLoggedIn", err)
+	}
+	if e := nextEvent(t, d); e.Type != EventError {
+		t.Fatalf("event %s, want error", e.Type)
+	}
+
+	if err := d.Login(ctx); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if !d.LoggedIn() || d.UserIP() != "10.0.0.2" || d.ACIP() != "10.0.0.1" {
+		t.Fatalf("after Login: logged in %v, user ip %q, ac ip %q", d.LoggedIn(), d.UserIP(), d.ACIP())
+	}
+	if e := nextEvent(t, d); e.Type != EventLoggedIn || e.Time.IsZero() {
+		t.Fatalf("event %+v, want logged-in", e)
+	}
+
+	next, err := d.Keepalive(ctx)
+	if err != nil || next != 2*time.Second {
+		t.Fatalf("Keepalive = %s, %v, want 2s", next, err)
+	}
+	if e := nextEvent(t, d); e.Type != EventKeepalive || e.Next != 2*time.Second {
+		t.Fatalf("event %+v, want keepalive", e)
+	}
+
+	if err := d.Logout(ctx); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if d.LoggedIn() || portal.logged.Load() {
+		t.Fatal("Logout did not terminate the session")
+	}
+	if e := nextEvent(t, d); e.Type != EventLoggedOut || e.Err != nil {
+		t.Fatalf("event %+v, want logged-out", e)
+	}
+	if err := d.Logout(ctx); !errors.Is(err, ErrNotLoggedIn) {
+		t.Fatalf("second Logout: %v, want ErrNotLoggedIn", err)
+	}
+
+	d.Close()
+	d.Close()
+	if _, ok := <-d.Events(); ok {
+		t.Fatal("event channel open after Close")
+	}
+	if err := d.Login(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("Login after Close: %v, want ErrClosed", err)
+	}
+	if _, err := d.Keepalive(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("Keepalive after Close: %v, want ErrClosed", err)
+	}
+	if err := d.Logout(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("Logout after Close: %v, want ErrClosed", err)
+	}
+}
+
+func TestDialerConnected(t *testing.T) {
+	portal := newFakePortal(t, false)
+	portal.logged.Store(true)
+	d := newTestDialer(t, portal)
+
+	if err := d.Login(context.Background()); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if d.LoggedIn() || len(portal.sentCodes()) != 0 {
+		t.Error("Login authorized an already connected network")
+	}
+}
+
+func TestDialerCodeRequired(t *testing.T) {
+	ctx := context.Background()
+	portal := newFakePortal(t, true)
+
+	d := newTestDialer(t, portal)
+	if err := d.Login(ctx); !errors.Is(err, ErrCodeRequired) {
+		t.Fatalf("Login without a code provider: %v, want ErrCodeRequired", err)
+	}
+	if e := nextEvent(t, d); e.Type != EventError || !errors.Is(e.Err, ErrCodeRequired) {
+		t.Fatalf("event %+v, want error", e)
+	}
+
+	var asked atomic.Int32
+	d = newTestDialer(t, portal, WithCodeProvider(CodeFunc(func(ctx context.Context, user string) (string, error) {
+		asked.Add(1)
+		if user != "13800000000" {
+			t.Errorf("code asked for %q", user)
+		}
+		return "123456", nil
+	})))
+	if err := d.Login(ctx); err != nil {
+		t.Fatalf("Login with a code provider: %v", err)
+	}
+	if asked.Load() != 1 {
+		t.Errorf("code provider called %d times, want 1", asked.Load())
+	}
+	if codes := portal.sentCodes(); len(codes) != 1 || codes[0] != "123456" {
+		t.Errorf("portal received codes %q, want [123456]", codes)
+	}
+}
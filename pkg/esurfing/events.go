@@ -0,0 +1,38 @@
+package esurfing
+
+import "time"
+
+const eventBuffer = 16
+
+// EventType identifies what happened in an Event
+type EventType int
+
+const (
+	EventLoggedIn EventType = iota
+	EventKeepalive
+	EventLoggedOut
+	EventError
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventLoggedIn:
+		return "logged-in"
+	case EventKeepalive:
+		return "keepalive"
+	case EventLoggedOut:
+		return "logged-out"
+	case EventError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a state change of a Dialer
+type Event struct {
+	Type EventType
+	Time time.Time
+	Next time.Duration // Interval until the next keepalive, for EventKeepalive
+	Err  error         // Failure cause, for EventError and a failed EventLoggedOut
+}
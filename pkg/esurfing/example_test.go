@@ -0,0 +1,71 @@
+package esurfing_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Rsplwe/ESurfingDialer/pkg/esurfing"
+)
+
+// Logs in, asking for the SMS verification code on stdin when the portal
+// wants one, and keeps the session alive until the heartbeats fail
+func Example() {
+	ctx := context.Background()
+	d, err := esurfing.New(
+		esurfing.WithCredentials("13800000000", "password"),
+		esurfing.WithCodeProvider(esurfing.CodeFunc(func(ctx context.Context, user string) (string, error) {
+			fmt.Printf("SMS code sent to %s: ", user)
+			code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			return strings.TrimSpace(code), err
+		})),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer d.Close()
+
+	if err := d.Login(ctx); err != nil {
+		if errors.Is(err, esurfing.ErrWrongPassword) {
+			log.Fatal("wrong user or password")
+		}
+		log.Fatal(err)
+	}
+	if !d.LoggedIn() {
+		fmt.Println("already connected")
+		return
+	}
+	fmt.Println("logged in as", d.UserIP())
+
+	for {
+		next, err := d.Keepalive(ctx)
+		if err != nil {
+			log.Println("keepalive failed:", err)
+			break
+		}
+		time.Sleep(next)
+	}
+	if err := d.Logout(ctx); err != nil {
+		log.Println("logout failed:", err)
+	}
+}
+
+// Events reports the dialer state changes to another goroutine
+func ExampleDialer_Events() {
+	d, err := esurfing.New(esurfing.WithCredentials("13800000000", "password"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		for e := range d.Events() {
+			log.Printf("%s at %s, err %v", e.Type, e.Time.Format(time.TimeOnly), e.Err)
+		}
+	}()
+	defer d.Close()
+	// d.Login(ctx) and so on
+}
@@ -0,0 +1,92 @@
+package esurfing
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// Option configures a Dialer
+type Option func(*config)
+
+type config struct {
+	user      string
+	password  string
+	smsCode   string
+	iface     string
+	mac       string
+	logger    *slog.Logger
+	transport http.RoundTripper
+	codes     CodeProvider
+	probes    []string
+	parallel  bool
+}
+
+// WithCredentials sets the login user (phone number or other) and password
+func WithCredentials(user, password string) Option {
+	return func(c *config) {
+		c.user = user
+		c.password = password
+	}
+}
+
+// WithSMSCode pre-enters the SMS verification code
+func WithSMSCode(code string) Option {
+	return func(c *config) {
+		c.smsCode = code
+	}
+}
+
+// WithCodeProvider sets where SMS verification codes come from when the portal
+// asks for one. Without it such a login fails with ErrCodeRequired.
+func WithCodeProvider(p CodeProvider) Option {
+	return func(c *config) {
+		c.codes = p
+	}
+}
+
+// WithInterface binds all requests to a network interface (e.g., eth0, wan)
+func WithInterface(name string) Option {
+	return func(c *config) {
+		c.iface = name
+	}
+}
+
+// WithMAC sets the MAC address reported to the portal, a random one is used by default
+func WithMAC(mac string) Option {
+	return func(c *config) {
+		c.mac = mac
+	}
+}
+
+// WithLogger sets the logger for dialer messages, slog.Default() is used by default.
+// Passwords, tickets and codes are redacted whatever its handler.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = logger
+	}
+}
+
+// WithProbes sets the connectivity check targets tried in order, each a preset
+// name such as google or apple, a URL, or URL|STATUS|BODY
+func WithProbes(specs ...string) Option {
+	return func(c *config) {
+		c.probes = specs
+	}
+}
+
+// WithParallelProbes races the connectivity check targets instead of trying them in order
+func WithParallelProbes() Option {
+	return func(c *config) {
+		c.parallel = true
+	}
+}
+
+// WithHTTPTransport sets the HTTP transport used for portal, probe and verification
+// requests. A *http.Transport without a dialer of its own still binds WithInterface,
+// any other round tripper or a transport with its own dialer must bind it itself.
+// TCP and DNS liveness checks always dial through WithInterface.
+func WithHTTPTransport(rt http.RoundTripper) Option {
+	return func(c *config) {
+		c.transport = rt
+	}
+}